@@ -3,9 +3,13 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"sort"
+	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/sgreben/render/pkg/render"
 	"github.com/sirupsen/logrus"
@@ -15,30 +19,48 @@ var config render.Config
 var printVersionFlag bool
 var printConfigFlag bool
 var printFuncsFlag bool
+var printVarsProvenanceFlag bool
+var printFrontMatterFlag bool
+var lintJSONFlag bool
+var watchInterval time.Duration
+var listenAddress string
 var version string
 var logger *logrus.Entry
 
 func init() {
 	logger = logrus.NewEntry(logrus.StandardLogger())
 
-	varsSourcesParameter := varsSourcesParameter{&config.VarsSources}
+	varsSourcesParameterJSON := varsSourcesParameter{&config.VarsSources, "json"}
+	varsSourcesParameterYAML := varsSourcesParameter{&config.VarsSources, "yaml"}
+	varsSourcesParameter := varsSourcesParameter{&config.VarsSources, ""}
 	varsSourcesFile := varsSourcesFile{&config.VarsSources}
 	varsSourcesFileSlurp := varsSourcesFileSlurp{&config.VarsSources}
+	varsSourcesFileDocuments := varsSourcesFileDocuments{&config.VarsSources}
 	varsSourcesFilesSlurp := varsSourcesFilesSlurp{&config.VarsSources}
 	varsSourcesEnvPrefix := varsSourcesEnv{&config.VarsSources}
 
+	partialSources := templateSourcesFileGlob{&config.PartialSources}
 	templateSourcesParameter := templateSourcesParameter{&config.TemplateSources}
 	templateSourcesFile := templateSourcesFile{&config.TemplateSources}
 	templateSourcesFileGlob := templateSourcesFileGlob{&config.TemplateSources}
+	templateSourcesDir := templateSourcesDir{&config.TemplateSources}
+	templateSourcesDirInclude := templateSourcesDirFilter{&config.TemplateSources, false}
+	templateSourcesDirExclude := templateSourcesDirFilter{&config.TemplateSources, true}
+	templateForEach := templateForEach{&config.TemplateSources}
+	templateCopy := stringList{&config.TemplateCopy}
+	templateOutModes := templateOutModes{&config.TemplateOutModes}
 
 	configPath := configPathParameter{&config}
 
 	flag.Var(&configPath, "config", "path to a config file")
 
-	flag.Var(&varsSourcesParameter, "var", "a single variable definition (<variable>=<value>)")
+	flag.Var(&varsSourcesParameter, "var", "a single variable definition; the variable may be a path such as a.b[0], and := parses the value as YAML (<variable>=<value> or <variable>:=<value>)")
+	flag.Var(&varsSourcesParameterJSON, "var-json", "a single variable definition with a JSON value (<variable>=<json>)")
+	flag.Var(&varsSourcesParameterYAML, "var-yaml", "a single variable definition with a YAML value (<variable>=<yaml>)")
 	flag.Var(&varsSourcesFileSlurp, "var-file-slurp", "set a single variable to a file's contents (or stdin, if - is given) (<variable>=<path>)")
 	flag.Var(&varsSourcesFilesSlurp, "var-files-slurp", "load all files matching the given glob pattern as variables (<key>=<glob>)")
-	flag.Var(&varsSourcesFile, "var-file", "load variable values from a file (or stdin, if - is given) ([<key>=]<path>)")
+	flag.Var(&varsSourcesFile, "var-file", "load variable values from a file (or stdin, if - is given) in the given format (json, yaml, toml, dotenv or properties), or the format indicated by the file name ([<key>=][<format>:]<path>)")
+	flag.Var(&varsSourcesFileDocuments, "var-file-documents", "load all documents of a YAML stream or all records of a JSON-lines file (or stdin, if - is given) as a list (<key>=[<format>:]<path>)")
 	flag.Var(&varsSourcesEnvPrefix, "var-env", "load variables matching the given glob pattern from the environment ([<key>=]<glob>)")
 
 	flag.Var(&templateSourcesParameter, "template", "load a template passed as a parameter ([<template-name>=]<template>)")
@@ -46,20 +68,48 @@ func init() {
 	flag.Var(&templateSourcesFile, "template-file", "load a template from a file (or stdin, if - is given) ([<template-name>=]<path>)")
 	flag.Var(&templateSourcesFile, "f", "(short for -template-file)")
 	flag.Var(&templateSourcesFileGlob, "template-files", "load templates from a set of files matching the given pattern (<glob>)")
+	flag.Var(&templateSourcesDir, "template-dir", "load all templates in a directory tree, named by their path relative to the directory (<path>)")
+	flag.Var(&templateSourcesDirInclude, "template-dir-include", "only load files from the preceding -template-dir whose path relative to the directory matches the given glob pattern (<glob>)")
+	flag.Var(&templateSourcesDirExclude, "template-dir-exclude", "do not load files from the preceding -template-dir whose path relative to the directory matches the given glob pattern (<glob>)")
+	flag.Var(&partialSources, "partials", "load templates from a set of files matching the given pattern for use by other templates, without rendering them (<glob>)")
+	flag.StringVar(&config.TemplateMode, "mode", "", "how templates are rendered: text, html (with contextual auto-escaping), or auto (html for templates named *.html or *.htm) (default text)")
+	flag.StringVar(&config.TemplateLayout, "layout", "", "render each output template that defines templates within the layout template at the given path, whose blocks the template's definitions override (<path>)")
+	flag.Var(&templateCopy, "copy", "copy template files matching the given glob pattern to the output verbatim instead of rendering them; files with binary content are always copied (<glob>)")
+	flag.Var(&templateForEach, "for-each", "render the preceding template once per element of a list variable, naming each output using a template (<variable>=<output-name-template>)")
 
 	flag.StringVar(&config.ConfigOutPath, "set-config-output-file", "", "path to write the configuration to")
 	flag.StringVar(&config.VarsOutPath, "set-vars-output-file", "", "path to write variable values to")
+	flag.StringVar(&config.VarsSchema, "vars-schema", "", "path to a JSON Schema (as JSON, YAML or TOML) to validate variables against and take default values from")
 	flag.StringVar(&config.TemplateOutExclude, "set-template-excludes", "", "exclude templates matching the given glob pattern from being output")
 	flag.StringVar(&config.TemplateOutPath, "set-output-dir", "", "path to write rendered templates to")
 	flag.StringVar(&config.TemplateOutPath, "o", "", "(short for -set-output-dir)")
+	flag.StringVar(&config.TemplateOutName, "set-output-name", "", "template for the path below the output directory that each template is written to; .Template.Name, .Template.Dir and .Template.Base refer to the template's name")
+	flag.Var(&templateOutModes, "set-mode", "set the permissions of output files whose path below the output directory matches the given glob pattern; later patterns take precedence (<glob>=<octal-mode>)")
+	flag.StringVar(&config.TemplateOutDirMode, "set-dir-mode", "", "permissions of directories created in the output directory (<octal-mode>)")
+	flag.BoolVar(&config.TemplateFrontMatter, "front-matter", false, "read front matter (YAML between --- lines or TOML between +++ lines) at the top of template files; a header that is not valid front matter is rendered as part of the template")
+	flag.BoolVar(&config.TemplateOutStripSuffix, "strip-suffix", false, "strip the suffixes .tmpl, .gotmpl and .tpl from output file names")
+	flag.BoolVar(&config.TemplateOutPrune, "prune", false, "remove files from the output directory that were rendered by a previous run but not by this one")
 	flag.StringVar(&config.TemplateLeftDelim, "set-left-delim", "{{", "left template delimiter")
 	flag.StringVar(&config.TemplateRightDelim, "set-right-delim", "}}", "right template delimiter")
+	flag.BoolVar(&config.TemplateStrict, "strict", false, "fail when a template refers to an undefined variable instead of rendering its zero value")
+	flag.StringVar(&config.VarsMerge, "set-merge", "", "how variable sources are combined: overwrite (replace top-level variables) or deep-merge (merge maps recursively) (default overwrite)")
+	flag.StringVar(&config.VarsMergeLists, "set-merge-lists", "", "how lists are combined when deep-merging: replace, append, or merge-by-key (default replace)")
+	flag.StringVar(&config.VarsMergeListKey, "set-merge-list-key", "", "the key identifying list elements for merge-by-key (default name)")
 	flag.StringVar(&config.TemplateOutPrintSeparator, "set-separator", "", "separator template to print between templates when printing templates to stdout")
 
 	flag.BoolVar(&printConfigFlag, "print-config", false, "print config to stdout and exit")
 	flag.BoolVar(&config.VarsOutPrint, "print-vars", false, "print variables to stdout and exit")
+	flag.BoolVar(&printVarsProvenanceFlag, "print-vars-provenance", false, "print each variable's value and the source that set it (and the sources it overrode) to stdout and exit")
+	flag.BoolVar(&printFrontMatterFlag, "print-front-matter", false, "print the front matter of each template that has any to stdout and exit")
 	flag.BoolVar(&printFuncsFlag, "print-funcs", false, "print available functions and their types to stdout and exit")
 	flag.BoolVar(&config.TemplateOutPrint, "print-templates", false, "print rendered templates to stdout")
+	flag.BoolVar(&config.TemplateOutDiff, "diff", false, "print a diff between the rendered templates and the files in the output directory instead of writing them, and exit with status 1 if they differ")
+
+	flag.BoolVar(&config.Watch, "watch", false, "re-render whenever a template or variable file changes")
+	flag.DurationVar(&watchInterval, "set-watch-interval", 500*time.Millisecond, "how often to check for changes in watch mode")
+
+	flag.StringVar(&listenAddress, "listen", ":8080", "address to listen on in serve mode")
+	flag.BoolVar(&lintJSONFlag, "json", false, "print the lint report as JSON in lint mode")
 
 	flag.BoolVar(&printVersionFlag, "version", false, "print version and exit")
 }
@@ -89,8 +139,91 @@ func printVars(vars render.Vars) {
 	}
 }
 
-func printTemplates(templates render.Templates) {
-	err := templates.Render(config.TemplateOutExclude, config.TemplateOutPrintSeparator, os.Stdout)
+func loadVars() (render.Vars, error) {
+	vars := render.Vars{}
+	err := vars.FromConfig(&config)
+	return vars, err
+}
+
+func loadTemplates(funcs template.FuncMap, vars render.Vars) (render.Templates, error) {
+	templates := render.Templates{Vars: vars}
+	err := templates.FromConfig(funcs, &config)
+	return templates, err
+}
+
+func renderTemplates(templates render.Templates) error {
+	if config.TemplateOutPath != "" {
+		err := templates.RenderToDir(config.TemplateOutExclude, config.TemplateOutPath)
+		if err != nil {
+			return err
+		}
+	}
+	if config.TemplateOutPrint {
+		return templates.Render(config.TemplateOutExclude, config.TemplateOutPrintSeparator, os.Stdout)
+	}
+	return nil
+}
+
+// watch re-loads variables and templates and renders them whenever any of
+// the files they are read from change. Errors are logged, not fatal.
+func watch(funcs template.FuncMap, layouts []string) {
+	watcher := &render.Watcher{
+		Config:   &config,
+		Layouts:  layouts,
+		Interval: watchInterval,
+		Debounce: watchInterval,
+	}
+	logger.Info("watching for changes")
+	watcher.Watch(func() {
+		vars, err := loadVars()
+		if err != nil {
+			logger.WithError(err).Error()
+			return
+		}
+		templates, err := loadTemplates(funcs, vars)
+		watcher.Layouts = templates.LayoutPaths()
+		if err != nil {
+			logger.WithError(err).Error()
+			return
+		}
+		err = renderTemplates(templates)
+		if err != nil {
+			logger.WithError(err).Error()
+			return
+		}
+		logger.Info("rendered")
+	}, nil)
+}
+
+func diffTemplates(templates render.Templates) {
+	changed, err := templates.Diff(config.TemplateOutPath, os.Stdout)
+	if err != nil {
+		logger.WithError(err).Fatal()
+	}
+	if changed {
+		os.Exit(1)
+	}
+}
+
+// fatalVarsError logs each schema violation separately, if err consists of
+// those, and exits.
+func fatalVarsError(err error) {
+	if schemaErrors, ok := err.(render.SchemaErrors); ok {
+		for _, schemaError := range schemaErrors {
+			logger.WithField("pointer", schemaError.Pointer).Error(schemaError.Message)
+		}
+		logger.Fatal("variables do not match the schema")
+	}
+	logger.WithError(err).Fatal()
+}
+
+func printVarsProvenance() {
+	vars := render.Vars{}
+	provenance, err := vars.FromConfigWithProvenance(&config)
+	if err != nil {
+		fatalVarsError(err)
+	}
+	err = provenance.Save(os.Stdout, vars)
 	if err != nil {
 		logger.WithError(err).Fatal()
 	}
@@ -117,6 +250,34 @@ func writeConfig() {
 	}
 }
 
+// lint checks the templates without rendering them and exits with status 1
+// if there are any issues. Variable references are only checked if variable
+// sources are given.
+func lint(funcs template.FuncMap, vars render.Vars) {
+	report, err := render.Lint(funcs, &config, vars, len(config.VarsSources) > 0)
+	if err != nil {
+		logger.WithError(err).Fatal()
+	}
+	if lintJSONFlag {
+		err = report.Save(os.Stdout)
+	} else {
+		err = report.Print(os.Stdout)
+	}
+	if err != nil {
+		logger.WithError(err).Fatal()
+	}
+	if len(report.Issues) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printFrontMatter(templates render.Templates) {
+	err := templates.SaveFrontMatter(os.Stdout)
+	if err != nil {
+		logger.WithError(err).Fatal()
+	}
+}
+
 func printConfig() {
 	err := config.Save(os.Stdout)
 	if err != nil {
@@ -124,9 +285,57 @@ func printConfig() {
 	}
 }
 
+// serve renders templates over HTTP, re-loading variables and templates
+// when the process receives SIGHUP.
+func serve(funcs template.FuncMap) {
+	load := func() (*render.Templates, error) {
+		vars, err := loadVars()
+		if err != nil {
+			return nil, err
+		}
+		templates, err := loadTemplates(funcs, vars)
+		if err != nil {
+			return nil, err
+		}
+		return &templates, nil
+	}
+	templates, err := load()
+	if err != nil {
+		logger.WithError(err).Fatal()
+	}
+	server := &render.Server{}
+	server.SetTemplates(templates)
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			templates, err := load()
+			if err != nil {
+				logger.WithError(err).Error("reload failed, keeping previous templates")
+				continue
+			}
+			server.SetTemplates(templates)
+			logger.Info("reloaded")
+		}
+	}()
+
+	logger.WithField("address", listenAddress).Info("listening")
+	err = http.ListenAndServe(listenAddress, server)
+	if err != nil {
+		logger.WithError(err).Fatal()
+	}
+}
+
 func main() {
 	args := os.Args
-	flag.Parse()
+	command := ""
+	if len(args) > 1 && (args[1] == "serve" || args[1] == "lint") {
+		command = args[1]
+		flag.CommandLine.Parse(args[2:])
+	} else {
+		flag.Parse()
+	}
 
 	if printVersionFlag {
 		fmt.Println(version)
@@ -151,10 +360,19 @@ func main() {
 		return
 	}
 
-	vars := render.Vars{}
-	err := vars.FromConfig(&config)
+	if command == "serve" {
+		serve(funcs)
+		return
+	}
+
+	if printVarsProvenanceFlag {
+		printVarsProvenance()
+		return
+	}
+
+	vars, err := loadVars()
 	if err != nil {
-		logger.WithError(err).Fatal()
+		fatalVarsError(err)
 	}
 
 	if config.VarsOutPath != "" {
@@ -166,8 +384,6 @@ func main() {
 		return
 	}
 
-	templates := render.Templates{Vars: vars}
-
 	// Default behavior: no templates specified -> use stdin
 	if len(config.TemplateSources) == 0 {
 		config.TemplateSources = []*render.TemplateSource{
@@ -178,9 +394,9 @@ func main() {
 		}
 	}
 
-	err = templates.FromConfig(funcs, &config)
-	if err != nil {
-		logger.WithError(err).Fatal()
+	if command == "lint" {
+		lint(funcs, vars)
+		return
 	}
 
 	// Default behavior: interpret "-o -" as -print-templates
@@ -189,18 +405,34 @@ func main() {
 		config.TemplateOutPrint = true
 	}
 
-	if config.TemplateOutPath != "" {
-		err = templates.RenderToDir(config.TemplateOutExclude, config.TemplateOutPath)
-		if err != nil {
-			logger.WithError(err).Fatal()
-		}
-	} else {
-		// Default behavior: no output dir specified -> use stdout
+	// Default behavior: no output dir specified -> use stdout
+	if config.TemplateOutPath == "" {
 		config.TemplateOutPrint = true
 	}
 
-	if config.TemplateOutPrint {
-		printTemplates(templates)
+	templates, err := loadTemplates(funcs, vars)
+	if err == nil && printFrontMatterFlag {
+		printFrontMatter(templates)
+		return
+	}
+	if err == nil && config.TemplateOutDiff {
+		if config.TemplateOutPath == "" {
+			logger.Fatal("-diff requires an output directory (-o)")
+		}
+		diffTemplates(templates)
+		return
+	}
+	if err == nil {
+		err = renderTemplates(templates)
+	}
+	if err != nil {
+		if !config.Watch {
+			logger.WithError(err).Fatal()
+		}
+		logger.WithError(err).Error()
 	}
 
+	if config.Watch {
+		watch(funcs, templates.LayoutPaths())
+	}
 }
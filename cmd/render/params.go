@@ -10,11 +10,15 @@ import (
 )
 
 type varsSourcesParameter struct {
-	store *[]*render.VarsSource
+	store  *[]*render.VarsSource
+	format string
 }
 type varsSourcesFile struct {
 	store *[]*render.VarsSource
 }
+type varsSourcesFileDocuments struct {
+	store *[]*render.VarsSource
+}
 type varsSourcesFileSlurp struct {
 	store *[]*render.VarsSource
 }
@@ -25,27 +29,42 @@ type varsSourcesEnv struct {
 	store *[]*render.VarsSource
 }
 
-func (v *varsSourcesParameter) String() string  { return "" }
-func (v *varsSourcesFile) String() string       { return "" }
-func (v *varsSourcesFileSlurp) String() string  { return "" }
-func (v *varsSourcesFilesSlurp) String() string { return "" }
-func (v *varsSourcesEnv) String() string        { return "" }
+func (v *varsSourcesParameter) String() string     { return "" }
+func (v *varsSourcesFile) String() string          { return "" }
+func (v *varsSourcesFileDocuments) String() string { return "" }
+func (v *varsSourcesFileSlurp) String() string     { return "" }
+func (v *varsSourcesFilesSlurp) String() string    { return "" }
+func (v *varsSourcesEnv) String() string           { return "" }
 
 func (v *varsSourcesParameter) Set(value string) error {
 	i := strings.IndexByte(value, byte('='))
 	if i <= 0 {
 		return errors.New("syntax: key=value")
 	}
+	key, format := value[:i], v.format
+	if format == "" && strings.HasSuffix(key, ":") {
+		key, format = key[:len(key)-1], "yaml"
+	}
 	varsSource := &render.VarsSource{
 		FromParameter: &render.VarsSourceParameter{
-			Key:   value[:i],
-			Value: value[i+1:],
+			Key:    key,
+			Value:  value[i+1:],
+			Format: format,
 		},
 	}
 	*v.store = append(*v.store, varsSource)
 	return nil
 }
 
+// splitFormat splits an optional format prefix ("yaml:config.txt") off a path.
+func splitFormat(value string) (string, string) {
+	i := strings.IndexByte(value, byte(':'))
+	if i > 0 && render.IsFormat(value[:i]) {
+		return value[:i], value[i+1:]
+	}
+	return "", value
+}
+
 func (v *varsSourcesFile) Set(value string) error {
 	var varsSource *render.VarsSource
 	i := strings.IndexByte(value, byte('='))
@@ -54,16 +73,20 @@ func (v *varsSourcesFile) Set(value string) error {
 		key = value[:i]
 		value = value[i+1:]
 	}
+	format, value := splitFormat(value)
 	if value == "-" {
 		varsSource = &render.VarsSource{
-			Key:       key,
-			FromStdin: &render.VarsSourceStdin{},
+			Key: key,
+			FromStdin: &render.VarsSourceStdin{
+				Format: format,
+			},
 		}
 	} else {
 		varsSource = &render.VarsSource{
 			Key: key,
 			FromFile: &render.VarsSourceFile{
-				Path: value,
+				Path:   value,
+				Format: format,
 			},
 		}
 	}
@@ -71,6 +94,23 @@ func (v *varsSourcesFile) Set(value string) error {
 	return nil
 }
 
+func (v *varsSourcesFileDocuments) Set(value string) error {
+	i := strings.IndexByte(value, byte('='))
+	if i <= 0 {
+		return errors.New("syntax: key=[format:]path")
+	}
+	format, path := splitFormat(value[i+1:])
+	varsSource := &render.VarsSource{
+		Key: value[:i],
+		FromFileDocuments: &render.VarsSourceFileDocuments{
+			Path:   path,
+			Format: format,
+		},
+	}
+	*v.store = append(*v.store, varsSource)
+	return nil
+}
+
 func (v *varsSourcesFileSlurp) Set(value string) error {
 	i := strings.IndexByte(value, byte('='))
 	if i <= 0 {
@@ -127,10 +167,14 @@ type templateSourcesFile struct {
 type templateSourcesFileGlob struct {
 	store *[]*render.TemplateSource
 }
+type templateSourcesDir struct {
+	store *[]*render.TemplateSource
+}
 
 func (v *templateSourcesParameter) String() string { return "" }
 func (v *templateSourcesFile) String() string      { return "" }
 func (v *templateSourcesFileGlob) String() string  { return "" }
+func (v *templateSourcesDir) String() string       { return "" }
 
 func (v *templateSourcesParameter) Set(value string) error {
 	i := strings.IndexByte(value, byte('='))
@@ -184,6 +228,92 @@ func (v *templateSourcesFileGlob) Set(value string) error {
 	return nil
 }
 
+func (v *templateSourcesDir) Set(value string) error {
+	TemplateSource := &render.TemplateSource{
+		Name: value,
+		FromDir: &render.TemplateSourceDir{
+			Path: value,
+		},
+	}
+	*v.store = append(*v.store, TemplateSource)
+	return nil
+}
+
+// templateSourcesDirFilter sets the include or exclude glob pattern of the
+// preceding -template-dir source. Patterns given several times are combined.
+type templateSourcesDirFilter struct {
+	store   *[]*render.TemplateSource
+	exclude bool
+}
+
+func (v *templateSourcesDirFilter) String() string { return "" }
+func (v *templateSourcesDirFilter) Set(value string) error {
+	if len(*v.store) == 0 || (*v.store)[len(*v.store)-1].FromDir == nil {
+		return errors.New("must follow a -template-dir flag")
+	}
+	fromDir := (*v.store)[len(*v.store)-1].FromDir
+	pattern := &fromDir.Include
+	if v.exclude {
+		pattern = &fromDir.Exclude
+	}
+	if *pattern != "" {
+		value = "{" + *pattern + "," + value + "}"
+	}
+	*pattern = value
+	return nil
+}
+
+type templateForEach struct {
+	store *[]*render.TemplateSource
+}
+
+func (v *templateForEach) String() string { return "" }
+func (v *templateForEach) Set(value string) error {
+	i := strings.IndexByte(value, byte('='))
+	if i <= 0 {
+		return errors.New("syntax: variable=output-name-template")
+	}
+	if len(*v.store) == 0 {
+		return errors.New("must follow a template flag")
+	}
+	TemplateSource := (*v.store)[len(*v.store)-1]
+	TemplateSource.ForEach = &render.TemplateForEach{
+		Var:    value[:i],
+		Output: value[i+1:],
+	}
+	return nil
+}
+
+type stringList struct {
+	store *[]string
+}
+
+func (v *stringList) String() string { return "" }
+func (v *stringList) Set(value string) error {
+	*v.store = append(*v.store, value)
+	return nil
+}
+
+type templateOutModes struct {
+	store *[]*render.TemplateOutMode
+}
+
+func (v *templateOutModes) String() string { return "" }
+func (v *templateOutModes) Set(value string) error {
+	i, j := strings.Index(value, "=>"), 2
+	if i < 0 {
+		i, j = strings.LastIndexByte(value, byte('=')), 1
+	}
+	if i <= 0 {
+		return errors.New("syntax: glob=mode")
+	}
+	*v.store = append(*v.store, &render.TemplateOutMode{
+		Glob: strings.TrimSpace(value[:i]),
+		Mode: strings.TrimSpace(value[i+j:]),
+	})
+	return nil
+}
+
 type configPathParameter struct {
 	store *render.Config
 }
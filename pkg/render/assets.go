@@ -0,0 +1,63 @@
+package render
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/gobwas/glob"
+)
+
+// asset is a file that is copied to the output verbatim instead of being
+// parsed as a template.
+type asset struct {
+	Content []byte
+}
+
+// binarySniffLength is how much of a file isBinary looks at.
+const binarySniffLength = 8192
+
+// isBinary reports whether data looks like binary rather than text content,
+// that is, whether its first binarySniffLength bytes contain a NUL byte. Text
+// in other encodings than UTF-8 is not considered binary.
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLength {
+		data = data[:binarySniffLength]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+func compileGlobs(patterns []string) ([]glob.Glob, error) {
+	globs := make([]glob.Glob, len(patterns))
+	for i, pattern := range patterns {
+		g, err := glob.Compile(pattern, '/')
+		if err != nil {
+			return nil, err
+		}
+		globs[i] = g
+	}
+	return globs, nil
+}
+
+func matchAny(globs []glob.Glob, name string) bool {
+	for _, g := range globs {
+		if g.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAsset reports whether the file with the given template name and content
+// should be copied rather than rendered.
+func (t *Templates) isAsset(name string, data []byte) bool {
+	return matchAny(t.Copy, name) || isBinary(data)
+}
+
+// defineAsset records the given file content as an asset, replacing any
+// previous template definition of the same name.
+func (t *Templates) defineAsset(name string, data []byte, mode os.FileMode) {
+	delete(t.FrontMatter, name)
+	delete(t.pages, name)
+	t.Assets[name] = &asset{Content: data}
+	t.Modes[name] = mode
+}
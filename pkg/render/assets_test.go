@@ -0,0 +1,27 @@
+package render
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		binary bool
+	}{
+		{"empty", nil, false},
+		{"UTF-8", []byte("café {{ .x }}"), false},
+		{"Latin-1", []byte("caf\xe9 {{ .x }}"), false},
+		{"NUL byte", []byte("\x89PNG\r\n\x1a\n\x00\x00"), true},
+		{"NUL byte after the sniffed prefix", append(bytes.Repeat([]byte("a"), binarySniffLength), 0), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isBinary(test.data); got != test.binary {
+				t.Errorf("expected %v, got %v", test.binary, got)
+			}
+		})
+	}
+}
@@ -10,17 +10,34 @@ import (
 
 // Config is the run-time configuration of the app
 type Config struct {
-	ConfigOutPath             string            `json:",omitempty"`
-	TemplateOutExclude        string            `json:",omitempty"`
-	TemplateOutPrintSeparator string            `json:",omitempty"`
-	TemplateOutPrint          bool              `json:",omitempty"`
-	TemplateOutPath           string            `json:",omitempty"`
-	TemplateLeftDelim         string            `json:",omitempty"`
-	TemplateRightDelim        string            `json:",omitempty"`
-	TemplateSources           []*TemplateSource `json:",omitempty"`
-	VarsOutPrint              bool              `json:",omitempty"`
-	VarsOutPath               string            `json:",omitempty"`
-	VarsSources               []*VarsSource     `json:",omitempty"`
+	ConfigOutPath             string             `json:",omitempty"`
+	TemplateOutExclude        string             `json:",omitempty"`
+	TemplateOutPrintSeparator string             `json:",omitempty"`
+	TemplateOutPrint          bool               `json:",omitempty"`
+	TemplateOutDiff           bool               `json:",omitempty"`
+	TemplateOutPrune          bool               `json:",omitempty"`
+	TemplateOutName           string             `json:",omitempty"`
+	TemplateOutModes          []*TemplateOutMode `json:",omitempty"`
+	TemplateOutDirMode        string             `json:",omitempty"`
+	TemplateOutStripSuffix    bool               `json:",omitempty"`
+	TemplateOutPath           string             `json:",omitempty"`
+	TemplateCopy              []string           `json:",omitempty"`
+	TemplateFrontMatter       bool               `json:",omitempty"`
+	TemplateMode              string             `json:",omitempty"`
+	TemplateLayout            string             `json:",omitempty"`
+	TemplateLeftDelim         string             `json:",omitempty"`
+	TemplateRightDelim        string             `json:",omitempty"`
+	TemplateStrict            bool               `json:",omitempty"`
+	TemplateSources           []*TemplateSource  `json:",omitempty"`
+	PartialSources            []*TemplateSource  `json:",omitempty"`
+	VarsMerge                 string             `json:",omitempty"`
+	VarsMergeLists            string             `json:",omitempty"`
+	VarsMergeListKey          string             `json:",omitempty"`
+	VarsOutPrint              bool               `json:",omitempty"`
+	VarsOutPath               string             `json:",omitempty"`
+	VarsSchema                string             `json:",omitempty"`
+	VarsSources               []*VarsSource      `json:",omitempty"`
+	Watch                     bool               `json:",omitempty"`
 }
 
 func (c *Config) Save(w io.Writer) error {
@@ -43,10 +60,5 @@ func (c *Config) Load(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(bytes, &c)
-	return err
-	if err == nil {
-		return nil
-	}
-	return yaml.Unmarshal(bytes, &c)
+	return json.Unmarshal(bytes, &c)
 }
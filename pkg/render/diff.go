@@ -0,0 +1,214 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const diffContextLines = 3
+
+type diffOp struct {
+	Kind byte // ' ', '-' or '+'
+	Line string
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b using the linear
+// space variant of Myers' algorithm: after stripping the common prefix and
+// suffix, it finds a point on an optimal path by searching from both ends
+// (see middleSnake), and diffs the parts before and after it recursively.
+func diffLines(a, b []string) []diffOp {
+	ops := []diffOp{}
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+	x, y, ok := 0, 0, false
+	if len(a) > 0 && len(b) > 0 {
+		x, y, ok = middleSnake(a, b)
+	}
+	if ok && (x > 0 || y > 0) && (x < len(a) || y < len(b)) {
+		ops = append(ops, diffLines(a[:x], b[:y])...)
+		ops = append(ops, diffLines(a[x:], b[y:])...)
+	} else {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+	}
+	for _, line := range common {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// middleSnake runs Myers' algorithm forwards from the start and backwards
+// from the end of a and b at the same time, until the two searches overlap.
+// The point (x, y) where they do lies on a shortest edit script. It reports
+// false if the searches do not overlap, in which case a and b have no lines
+// in common.
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+	delta := n - m
+	odd := delta%2 != 0
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				kb := offset + delta - k
+				if kb >= 0 && kb < len(vb) && vb[kb] != -1 && x >= n-vb[kb] {
+					return x, y, true
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				kf := offset + delta - k
+				if kf >= 0 && kf < len(vf) && vf[kf] != -1 {
+					xf := vf[kf]
+					if xf >= n-x {
+						return xf, offset + xf - kf, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// unifiedDiff writes a unified diff between a and b to w.
+func unifiedDiff(w io.Writer, fromName, toName string, a, b []byte) error {
+	ops := diffLines(splitLines(a), splitLines(b))
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].Kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		first := start - diffContextLines
+		if first < 0 {
+			first = 0
+		}
+		last, unchanged := start, 0
+		for i := start; i < len(ops) && unchanged <= 2*diffContextLines; i++ {
+			if ops[i].Kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+				last = i
+			}
+		}
+		end := last + 1 + diffContextLines
+		if end > len(ops) {
+			end = len(ops)
+		}
+		aStart, bStart := 0, 0
+		for _, op := range ops[:first] {
+			if op.Kind != '+' {
+				aStart++
+			}
+			if op.Kind != '-' {
+				bStart++
+			}
+		}
+		aLines, bLines := 0, 0
+		for _, op := range ops[first:end] {
+			if op.Kind != '+' {
+				aLines++
+			}
+			if op.Kind != '-' {
+				bLines++
+			}
+		}
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(aStart, aLines), hunkRange(bStart, bLines))
+		for _, op := range ops[first:end] {
+			buf.WriteByte(op.Kind)
+			buf.WriteString(op.Line)
+			if !strings.HasSuffix(op.Line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = end
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if lines == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, lines)
+}
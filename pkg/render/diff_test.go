@@ -0,0 +1,171 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] > lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	return lengths[0][0]
+}
+
+func TestDiffLines(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, random.Intn(20))
+		for i := range lines {
+			lines[i] = string('a' + rune(random.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+		var fromA, fromB []string
+		edits := 0
+		for _, op := range ops {
+			if op.Kind != '+' {
+				fromA = append(fromA, op.Line)
+			}
+			if op.Kind != '-' {
+				fromB = append(fromB, op.Line)
+			}
+			if op.Kind != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(fromA, "") != strings.Join(a, "") || strings.Join(fromB, "") != strings.Join(b, "") {
+			t.Fatalf("%q -> %q: edit script %v does not transform one into the other", a, b, ops)
+		}
+		if expected := len(a) + len(b) - 2*lcsLength(a, b); edits != expected {
+			t.Fatalf("%q -> %q: expected %d edits, got %d", a, b, expected, edits)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	a, b := make([]string, 5000), make([]string, 5000)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d\n", i)
+		b[i] = fmt.Sprintf("b%d\n", i)
+	}
+	ops := diffLines(a, b)
+	if len(ops) != len(a)+len(b) {
+		t.Fatalf("expected %d edits, got %d", len(a)+len(b), len(ops))
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		diff string
+	}{
+		{
+			name: "changed line",
+			a:    "1\n2\n3\n",
+			b:    "1\nx\n3\n",
+			diff: "@@ -1,3 +1,3 @@\n 1\n-2\n+x\n 3\n",
+		},
+		{
+			name: "created file",
+			a:    "",
+			b:    "1\n2\n",
+			diff: "@@ -0,0 +1,2 @@\n+1\n+2\n",
+		},
+		{
+			name: "removed line",
+			a:    "1\n",
+			b:    "",
+			diff: "@@ -1 +0,0 @@\n-1\n",
+		},
+		{
+			name: "no newline at end of file",
+			a:    "1\n2",
+			b:    "1\n2\n",
+			diff: "@@ -1,2 +1,2 @@\n 1\n-2\n\\ No newline at end of file\n+2\n",
+		},
+		{
+			name: "context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "1\n2\n3\n4\n5\n6\n7\nx\n",
+			diff: "@@ -5,4 +5,4 @@\n 5\n 6\n 7\n-8\n+x\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			b:    "x\n1\n2\n3\n4\n5\n6\n7\n8\ny\n",
+			diff: "@@ -1,4 +1,4 @@\n-a\n+x\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+y\n",
+		},
+		{
+			name: "merged hunks",
+			a:    "a\n1\n2\n3\n4\n5\n6\nb\n",
+			b:    "x\n1\n2\n3\n4\n5\n6\ny\n",
+			diff: "@@ -1,8 +1,8 @@\n-a\n+x\n 1\n 2\n 3\n 4\n 5\n 6\n-b\n+y\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := unifiedDiff(buf, "old", "new", []byte(test.a), []byte(test.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := "--- old\n+++ new\n" + test.diff
+			if buf.String() != expected {
+				t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestDiffModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, mode := range map[string]os.FileMode{"same": 0644, "chmod": 0644} {
+		err = writeFileAtomic(filepath.Join(dir, name), []byte("x"), mode, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := &Config{TemplateOutModes: []*TemplateOutMode{{Glob: "same", Mode: "0644"}, {Glob: "chmod", Mode: "0755"}}}
+	templates := parameterTemplates(t, config, "same", "x", "chmod", "x")
+	buf := &bytes.Buffer{}
+	changed, err := templates.Diff(dir, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected a change to be reported")
+	}
+	expected := fmt.Sprintf("would change mode of %s from 0644 to 0755\n", filepath.Join(dir, "chmod"))
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
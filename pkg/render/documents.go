@@ -0,0 +1,147 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var yamlDocumentSeparatorPattern = regexp.MustCompile(`^(---|\.\.\.)(\s.*)?$`)
+
+// splitYAMLDocuments splits a YAML stream into its documents, returning each
+// along with the number of lines preceding it. Content following a "---" on
+// the same line, such as "--- {a: 1}" or "--- |", starts the next document.
+func splitYAMLDocuments(data []byte) ([]string, []int) {
+	documents := []string{}
+	offsets := []int{}
+	current := []string{}
+	start := 0
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		match := yamlDocumentSeparatorPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match != nil {
+			documents = append(documents, strings.Join(append(current, ""), "\n"))
+			offsets = append(offsets, start)
+			current = []string{}
+			start = i + 1
+			if rest := strings.TrimSpace(match[2]); match[1] == "---" && rest != "" {
+				current = append(current, rest)
+				start = i
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	documents = append(documents, strings.Join(current, "\n"))
+	offsets = append(offsets, start)
+	return documents, offsets
+}
+
+// parseYAMLDocuments parses all non-empty documents of a YAML stream.
+func parseYAMLDocuments(name string, data []byte) ([]interface{}, error) {
+	result := []interface{}{}
+	documents, offsets := splitYAMLDocuments(data)
+	for i, document := range documents {
+		var value interface{}
+		err := yaml.Unmarshal([]byte(document), &value)
+		if err != nil {
+			parseError := newParseError(name, FormatYAML, []byte(document), err)
+			if parseError.Line > 0 {
+				parseError.Line += offsets[i]
+			}
+			return nil, parseError
+		}
+		if value != nil {
+			result = append(result, flatten(value))
+		}
+	}
+	return result, nil
+}
+
+// parseJSONLines parses newline-delimited JSON, one value per non-empty line.
+func parseJSONLines(name string, data []byte) ([]interface{}, error) {
+	result := []interface{}{}
+	for i, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var value interface{}
+		err := json.Unmarshal(line, &value)
+		if err != nil {
+			parseError := newParseError(name, FormatJSON, line, err)
+			parseError.Line = i + 1
+			return nil, parseError
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// formatYAMLDocuments formats each value as a YAML document, separated by "---".
+func formatYAMLDocuments(values []interface{}) (string, error) {
+	documents := make([]string, len(values))
+	for i, value := range values {
+		bytes, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		documents[i] = string(bytes)
+	}
+	return strings.Join(documents, "---\n"), nil
+}
+
+// VarsSourceFileDocuments loads all documents of a YAML stream, or all
+// records of a newline-delimited JSON file, as a list. The Format ("yaml" or
+// "json") is detected from the file's name if not given, or failing that
+// guessed from its contents. If Path is "-", stdin is read.
+type VarsSourceFileDocuments struct {
+	Path   string
+	Format string `json:",omitempty"`
+}
+
+func (v VarsSourceFileDocuments) format(data []byte) string {
+	if v.Format != "" {
+		return v.Format
+	}
+	switch strings.ToLower(filepath.Ext(v.Path)) {
+	case ".ndjson", ".jsonl", ".json":
+		return FormatJSON
+	case ".yml", ".yaml":
+		return FormatYAML
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+func (v VarsSourceFileDocuments) Load() ([]interface{}, error) {
+	var data []byte
+	var err error
+	name := v.Path
+	if v.Path == "-" {
+		name = "stdin"
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(v.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch format := v.format(data); format {
+	case FormatJSON:
+		return parseJSONLines(name, data)
+	case FormatYAML:
+		return parseYAMLDocuments(name, data)
+	default:
+		return nil, fmt.Errorf("%s: format %q does not support multiple documents (expected %q or %q)", name, format, FormatYAML, FormatJSON)
+	}
+}
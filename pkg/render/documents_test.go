@@ -0,0 +1,82 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAMLDocuments(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		values []interface{}
+		line   int
+	}{
+		{
+			name:   "separated documents",
+			data:   "a: 1\n---\na: 2\n",
+			values: []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}},
+		},
+		{
+			name:   "leading separator and end markers",
+			data:   "---\na: 1\n...\n---\na: 2\n...\n",
+			values: []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}},
+		},
+		{
+			name:   "content after the separator",
+			data:   "--- {a: 1}\n--- {a: 2}\n",
+			values: []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}},
+		},
+		{
+			name:   "scalar after the separator",
+			data:   "--- 1\n--- x\n",
+			values: []interface{}{1, "x"},
+		},
+		{
+			name:   "block scalars",
+			data:   "--- |\n  line 1\n  line 2\n--- >\n  folded\n  text\n",
+			values: []interface{}{"line 1\nline 2\n", "folded text\n"},
+		},
+		{
+			name:   "block scalar in a mapping",
+			data:   "a: |\n  x\n---\nb: z\n",
+			values: []interface{}{map[string]interface{}{"a": "x\n"}, map[string]interface{}{"b": "z"}},
+		},
+		{
+			name:   "comment after the separator",
+			data:   "--- # first\na: 1\n",
+			values: []interface{}{map[string]interface{}{"a": 1}},
+		},
+		{
+			name:   "empty documents",
+			data:   "---\n---\n",
+			values: []interface{}{},
+		},
+		{
+			name: "error line",
+			data: "a: 1\n--- |\n  x\n---\nb: 1\nc: [\n",
+			line: 6,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := parseYAMLDocuments("test", []byte(test.data))
+			if test.line > 0 {
+				parseError, ok := err.(*ParseError)
+				if !ok {
+					t.Fatalf("expected a parse error, got %v", err)
+				}
+				if parseError.Line != test.line {
+					t.Errorf("expected the error on line %d, got %d", test.line, parseError.Line)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, test.values) {
+				t.Errorf("expected %#v, got %#v", test.values, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var dotenvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+var dotenvPlainValuePattern = regexp.MustCompile(`^[A-Za-z0-9_./:@,+-]*$`)
+
+// parseDotenv parses the contents of a .env file. Values may be unquoted,
+// single-quoted (taken literally) or double-quoted (with backslash escapes);
+// quoted values may span several lines. References to variables (${VAR},
+// ${VAR:-default} or $VAR) in unquoted and double-quoted values are replaced
+// by variables defined earlier in the file or, failing that, by lookup.
+func parseDotenv(data []byte, lookup func(string) (string, bool)) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	expandLookup := func(key string) (string, bool) {
+		if value, ok := vars[key]; ok {
+			return value.(string), true
+		}
+		if lookup != nil {
+			return lookup(key)
+		}
+		return "", false
+	}
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	for n := 0; n < len(lines); n++ {
+		lineNumber := n + 1
+		line := strings.TrimSpace(lines[n])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+		key := strings.TrimSpace(line[:i])
+		if !dotenvKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", lineNumber, key)
+		}
+		value := strings.TrimLeft(line[i+1:], " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = value[:j]
+			}
+			vars[key] = expandDotenv(strings.TrimSpace(value), expandLookup)
+			continue
+		}
+		quote := value[0]
+		value = value[1:]
+		buf := &bytes.Buffer{}
+		closed := false
+		for !closed {
+			for j := 0; j < len(value); j++ {
+				c := value[j]
+				if c == quote {
+					closed = true
+					rest := strings.TrimSpace(value[j+1:])
+					if rest != "" && !strings.HasPrefix(rest, "#") {
+						return nil, fmt.Errorf("line %d: unexpected %q after closing quote", lineNumber, rest)
+					}
+					break
+				}
+				if quote == '"' && c == '\\' && j+1 < len(value) {
+					j++
+					switch value[j] {
+					case 'n':
+						buf.WriteByte('\n')
+					case 'r':
+						buf.WriteByte('\r')
+					case 't':
+						buf.WriteByte('\t')
+					case '$':
+						// Keep escaped dollar signs out of variable expansion.
+						buf.WriteString("$$")
+					default:
+						buf.WriteByte(value[j])
+					}
+					continue
+				}
+				buf.WriteByte(c)
+			}
+			if closed {
+				break
+			}
+			n++
+			if n >= len(lines) {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", lineNumber)
+			}
+			buf.WriteByte('\n')
+			value = lines[n]
+		}
+		if quote == '\'' {
+			vars[key] = buf.String()
+		} else {
+			vars[key] = expandDotenv(buf.String(), expandLookup)
+		}
+	}
+	return vars, nil
+}
+
+// dotenvReferencePattern matches variable references. Unlike in keys, a "-"
+// in a reference starts the default value, as in the shell.
+var dotenvReferencePattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_.]*)(:?-([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+func expandDotenv(value string, lookup func(string) (string, bool)) string {
+	return dotenvReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$$" {
+			return "$"
+		}
+		match := dotenvReferencePattern.FindStringSubmatch(reference)
+		key := match[1]
+		if key == "" {
+			key = match[4]
+		}
+		expanded, ok := lookup(key)
+		if match[2] != "" && (!ok || (expanded == "" && strings.HasPrefix(match[2], ":"))) {
+			return match[3]
+		}
+		return expanded
+	})
+}
+
+// formatDotenv formats a map of scalar values as a .env file.
+func formatDotenv(vars map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	buf := &bytes.Buffer{}
+	for _, key := range keys {
+		if !dotenvKeyPattern.MatchString(key) {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+		value := vars[key]
+		if _, ok := asMap(value); ok {
+			return "", fmt.Errorf("value of %q is a map, not a scalar", key)
+		}
+		if _, ok := value.([]interface{}); ok {
+			return "", fmt.Errorf("value of %q is a list, not a scalar", key)
+		}
+		s := ""
+		if value != nil {
+			s = fmt.Sprint(value)
+		}
+		if !dotenvPlainValuePattern.MatchString(s) {
+			s = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "$", `\$`).Replace(s) + `"`
+		}
+		fmt.Fprintf(buf, "%s=%s\n", key, s)
+	}
+	return buf.String(), nil
+}
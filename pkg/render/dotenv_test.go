@@ -0,0 +1,96 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	env := map[string]string{"HOME": "/home/user", "EMPTY": ""}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	tests := []struct {
+		name string
+		data string
+		vars map[string]interface{}
+		err  string
+	}{
+		{"plain", "A=1\nB = two \n", map[string]interface{}{"A": "1", "B": "two"}, ""},
+		{"comments and blank lines", "# comment\n\nA=1 # trailing\nB=x#y\n", map[string]interface{}{"A": "1", "B": "x#y"}, ""},
+		{"export", "export A=1\n", map[string]interface{}{"A": "1"}, ""},
+		{"empty value", "A=\n", map[string]interface{}{"A": ""}, ""},
+		{"CRLF", "A=1\r\nB=2\r\n", map[string]interface{}{"A": "1", "B": "2"}, ""},
+		{"single quotes", `A='$HOME \n # x'`, map[string]interface{}{"A": `$HOME \n # x`}, ""},
+		{"double quotes", `A="a\tb\n\"c\" \\ \$HOME" # comment`, map[string]interface{}{"A": "a\tb\n\"c\" \\ $HOME"}, ""},
+		{"multi-line", "A=\"one\ntwo\"\nB='three\nfour'\n", map[string]interface{}{"A": "one\ntwo", "B": "three\nfour"}, ""},
+		{"references", "A=x\nB=${A}/$A/$HOME/${MISSING}\n", map[string]interface{}{"A": "x", "B": "x/x//home/user/"}, ""},
+		{"defaults", "A=${MISSING-d1}/${EMPTY-d2}/${EMPTY:-d3}/${HOME:-d4}\n", map[string]interface{}{"A": "d1//d3//home/user"}, ""},
+		{"file takes precedence", "HOME=/root\nA=$HOME\n", map[string]interface{}{"HOME": "/root", "A": "/root"}, ""},
+		{"missing equals sign", "A=1\nB\n", nil, "line 2: expected KEY=VALUE"},
+		{"invalid key", "1A=1\n", nil, `line 1: invalid key "1A"`},
+		{"unterminated quote", "A=1\nB=\"x\n", nil, "line 2: unterminated quoted value"},
+		{"text after quote", `A="x" y`, nil, `line 1: unexpected "y" after closing quote`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars, err := parseDotenv([]byte(test.data), lookup)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(vars, test.vars) {
+				t.Errorf("expected %q, got %q", test.vars, vars)
+			}
+		})
+	}
+}
+
+func TestFormatDotenv(t *testing.T) {
+	vars := map[string]interface{}{
+		"PLAIN":  "a/b:c",
+		"SPACES": "a b",
+		"QUOTES": `say "hi" \ $HOME`,
+		"LINES":  "one\ntwo\tthree",
+		"NUMBER": 1,
+		"NULL":   nil,
+	}
+	formatted, err := formatDotenv(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `LINES="one\ntwo\tthree"
+NULL=
+NUMBER=1
+PLAIN=a/b:c
+QUOTES="say \"hi\" \\ \$HOME"
+SPACES="a b"
+`
+	if formatted != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, formatted)
+	}
+	parsed, err := parseDotenv([]byte(formatted), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars["NUMBER"], vars["NULL"] = "1", ""
+	if !reflect.DeepEqual(parsed, vars) {
+		t.Errorf("expected %q, got %q", vars, parsed)
+	}
+	for _, invalid := range []map[string]interface{}{
+		{"A B": "x"},
+		{"A": map[string]interface{}{}},
+		{"A": []interface{}{}},
+	} {
+		if _, err := formatDotenv(invalid); err == nil {
+			t.Errorf("%v: expected an error", invalid)
+		}
+	}
+}
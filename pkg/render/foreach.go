@@ -0,0 +1,112 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"text/template"
+)
+
+// TemplateForEach renders a template once per element of the list variable
+// at the path Var (e.g. "tenants"). Each element must be a map, whose entries
+// are merged into the variables for that execution. The Output template
+// (e.g. "tenants/{{ .name }}.yaml") is executed with the same variables to
+// name the element's output.
+type TemplateForEach struct {
+	Var    string
+	Output string
+}
+
+type forEach struct {
+	varPath []interface{}
+	output  *template.Template
+	*TemplateForEach
+}
+
+func (t *Templates) newForEach(name string, f *TemplateForEach, leftDelim, rightDelim string) (*forEach, error) {
+	varPath, err := parsePath(f.Var)
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %v", name, err)
+	}
+	if f.Output == "" {
+		return nil, fmt.Errorf("template %q: no output name given for each element of %s", name, f.Var)
+	}
+	output := template.New(name+" (output name)").Delims(leftDelim, rightDelim).Funcs(t.Funcs)
+	t.setupTemplate(output)
+	_, err = output.Parse(f.Output)
+	if err != nil {
+		return nil, err
+	}
+	return &forEach{
+		varPath:         varPath,
+		output:          output,
+		TemplateForEach: f,
+	}, nil
+}
+
+// elementVars returns the variables to render each element of the list with.
+func (f *forEach) elementVars(vars map[string]interface{}) ([]map[string]interface{}, error) {
+	value, ok := getPath(vars, f.varPath)
+	if !ok {
+		return nil, fmt.Errorf("variable %s is not defined", f.Var)
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("variable %s is not a list", f.Var)
+	}
+	result := make([]map[string]interface{}, len(list))
+	for i, element := range list {
+		m, ok := asMap(element)
+		if !ok {
+			return nil, fmt.Errorf("element %s is not a map", joinIndex(f.Var, i))
+		}
+		elementVars := make(map[string]interface{}, len(vars)+len(m))
+		for key, value := range vars {
+			elementVars[key] = value
+		}
+		for key, value := range m {
+			elementVars[key] = value
+		}
+		result[i] = elementVars
+	}
+	return result, nil
+}
+
+// forEachOutputs renders the template once per element of the list.
+func (t *Templates) forEachOutputs(templateName string, e *execution, f *forEach) ([]*output, error) {
+	elementVars, err := f.elementVars(t.templateVars(templateName))
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %v", templateName, err)
+	}
+	outputs := []*output{}
+	seen := map[string]int{}
+	for i, vars := range elementVars {
+		name := &bytes.Buffer{}
+		err := t.execute(f.output, vars, name)
+		if err != nil {
+			return nil, err
+		}
+		outputName := path.Clean(name.String())
+		if name.Len() == 0 {
+			return nil, fmt.Errorf("template %q: output name of element %s is empty", templateName, joinIndex(f.Var, i))
+		}
+		if j, ok := seen[outputName]; ok {
+			return nil, fmt.Errorf("template %q: elements %s and %s have the same output name %q", templateName, joinIndex(f.Var, j), joinIndex(f.Var, i), outputName)
+		}
+		if outsideDir(outputName) {
+			return nil, fmt.Errorf("template %q: output name %q of element %s is outside the output directory", templateName, outputName, joinIndex(f.Var, i))
+		}
+		seen[outputName] = i
+		buf := &bytes.Buffer{}
+		files, err := e.execute(vars, buf)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &output{
+			Name:    outputName,
+			Content: buf.Bytes(),
+			files:   files,
+		})
+	}
+	return outputs, nil
+}
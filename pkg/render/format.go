@@ -0,0 +1,200 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Variable file formats
+const (
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatTOML       = "toml"
+	FormatDotenv     = "dotenv"
+	FormatProperties = "properties"
+)
+
+// Formats lists the supported variable file formats.
+var Formats = []string{FormatJSON, FormatYAML, FormatTOML, FormatDotenv, FormatProperties}
+
+// IsFormat reports whether format is a supported variable file format.
+func IsFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// formatOfPath detects the format of a variable file from its name. It
+// returns "" if the name does not indicate a format.
+func formatOfPath(path string) string {
+	base := filepath.Base(path)
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return FormatDotenv
+	}
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".json":
+		return FormatJSON
+	case ".yml", ".yaml":
+		return FormatYAML
+	case ".toml", ".tml":
+		return FormatTOML
+	case ".env":
+		return FormatDotenv
+	case ".properties":
+		return FormatProperties
+	}
+	return ""
+}
+
+// ParseError is an error in a variable file, along with its position.
+type ParseError struct {
+	Name   string
+	Format string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	position := e.Name
+	if e.Line > 0 {
+		position += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			position += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+	return fmt.Sprintf("%s: invalid %s: %v", position, strings.ToUpper(e.Format), e.Err)
+}
+
+var yamlErrorLinePattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+var tomlErrorLinePattern = regexp.MustCompile(`^Near line (\d+) \(last key parsed '(.*)'\): (.*)$`)
+var lineErrorPattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// offsetPosition converts a byte offset in data into a line and column (both starting at 1).
+func offsetPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := 1 + bytes.Count(before, []byte{'\n'})
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+func newParseError(name, format string, data []byte, err error) *ParseError {
+	parseError := &ParseError{Name: name, Format: format, Err: err}
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		parseError.Line, parseError.Column = offsetPosition(data, e.Offset)
+	case *json.UnmarshalTypeError:
+		parseError.Line, parseError.Column = offsetPosition(data, e.Offset)
+	case *yaml.TypeError:
+		parseError.Err = fmt.Errorf("%s", strings.Join(e.Errors, "; "))
+		if match := lineErrorPattern.FindStringSubmatch(e.Errors[0]); match != nil && len(e.Errors) == 1 {
+			parseError.Line, _ = strconv.Atoi(match[1])
+			parseError.Err = fmt.Errorf("%s", match[2])
+		}
+	default:
+		message := err.Error()
+		if match := yamlErrorLinePattern.FindStringSubmatch(message); match != nil {
+			parseError.Line, _ = strconv.Atoi(match[1])
+			parseError.Err = fmt.Errorf("%s", match[2])
+		} else if match := tomlErrorLinePattern.FindStringSubmatch(message); match != nil {
+			parseError.Line, _ = strconv.Atoi(match[1])
+			parseError.Err = fmt.Errorf("%s (after key %q)", match[3], match[2])
+		} else if match := lineErrorPattern.FindStringSubmatch(message); match != nil {
+			parseError.Line, _ = strconv.Atoi(match[1])
+			parseError.Err = fmt.Errorf("%s", match[2])
+		} else {
+			parseError.Err = fmt.Errorf("%s", strings.TrimPrefix(message, "yaml: "))
+		}
+	}
+	return parseError
+}
+
+// parseVars parses data in the given format. The name is used in error
+// messages. Empty (or whitespace-only) data has no variables in any format.
+func parseVars(name string, data []byte, format string, expandKeys bool) (map[string]interface{}, error) {
+	var vars map[string]interface{}
+	var err error
+	switch {
+	case !IsFormat(format):
+		return nil, fmt.Errorf("%s: unknown format %q (expected one of %s)", name, format, strings.Join(Formats, ", "))
+	case len(bytes.TrimSpace(data)) == 0:
+		return map[string]interface{}{}, nil
+	}
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &vars)
+	case FormatYAML:
+		raw := Vars{}
+		err = yaml.Unmarshal(data, &raw)
+		vars = flatten(raw).(map[string]interface{})
+	case FormatTOML:
+		raw := Vars{}
+		err = toml.Unmarshal(data, &raw)
+		vars = flatten(raw).(map[string]interface{})
+	case FormatDotenv:
+		vars, err = parseDotenv(data, os.LookupEnv)
+	case FormatProperties:
+		vars, err = parseProperties(data)
+		if err == nil && expandKeys {
+			vars, err = expandDottedKeys(vars)
+		}
+	}
+	if err != nil {
+		return nil, newParseError(name, format, data, err)
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	return vars, nil
+}
+
+// guessFormat returns the format data is most likely in: JSON if it looks
+// like a JSON object, otherwise the first of YAML and TOML that parses it.
+// If neither does, it returns the format whose error is reported furthest
+// into the data, which is most likely the one that was intended.
+func guessFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	best, bestLine := FormatYAML, -1
+	for _, format := range []string{FormatYAML, FormatTOML} {
+		_, err := parseVars("", data, format, false)
+		if err == nil {
+			return format
+		}
+		if parseError, ok := err.(*ParseError); ok && parseError.Line > bestLine {
+			best, bestLine = format, parseError.Line
+		}
+	}
+	return best
+}
+
+// fromFormat parses data in the given format (or, if format is empty, the
+// format guessed from its contents) and sets the resulting variables.
+func (v Vars) fromFormat(name string, data []byte, format string, expandKeys bool) error {
+	if format == "" {
+		format = guessFormat(data)
+	}
+	vars, err := parseVars(name, data, format, expandKeys)
+	if err != nil {
+		return err
+	}
+	v.overwriteWith(vars)
+	return nil
+}
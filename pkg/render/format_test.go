@@ -0,0 +1,63 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVarsEmpty(t *testing.T) {
+	for _, format := range Formats {
+		for _, data := range []string{"", " \n\t\n"} {
+			vars, err := parseVars("vars", []byte(data), format, false)
+			if err != nil {
+				t.Errorf("%s %q: %v", format, data, err)
+				continue
+			}
+			if len(vars) != 0 {
+				t.Errorf("%s %q: expected no variables, got %v", format, data, vars)
+			}
+		}
+	}
+}
+
+func TestGuessFormat(t *testing.T) {
+	tests := []struct {
+		data   string
+		format string
+	}{
+		{"", FormatYAML},
+		{" \n", FormatYAML},
+		{`{"a": 1}`, FormatJSON},
+		{"a: 1\n", FormatYAML},
+		{"[a]\nb = 1\n", FormatTOML},
+		{"a = \"x\"\n", FormatTOML},
+	}
+	for _, test := range tests {
+		if format := guessFormat([]byte(test.data)); format != test.format {
+			t.Errorf("%q: expected %s, got %s", test.data, test.format, format)
+		}
+	}
+}
+
+func TestFromFormat(t *testing.T) {
+	tests := []struct {
+		data string
+		vars Vars
+	}{
+		{"", Vars{}},
+		{`{"a": 1}`, Vars{"a": 1.0}},
+		{"a:\n  b: x\n", Vars{"a": map[string]interface{}{"b": "x"}}},
+		{"[a]\nb = \"x\"\n", Vars{"a": map[string]interface{}{"b": "x"}}},
+	}
+	for _, test := range tests {
+		vars := Vars{}
+		err := vars.fromFormat("vars", []byte(test.data), "", false)
+		if err != nil {
+			t.Errorf("%q: %v", test.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(vars, test.vars) {
+			t.Errorf("%q: expected %v, got %v", test.data, test.vars, vars)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// FrontMatter holds per-template settings given in a YAML (between "---"
+// lines) or TOML (between "+++" lines) block at the top of a template file.
+// Front matter is only read if Config.TemplateFrontMatter is set.
+type FrontMatter struct {
+	// Output is a template for the template's output path (see
+	// Config.TemplateOutName).
+	Output string `json:",omitempty" yaml:"output" toml:"output"`
+	// Mode is the output file's permissions in octal, e.g. "0755".
+	Mode string `json:",omitempty" yaml:"mode" toml:"mode"`
+	// Partial templates are not output, but can be used by other templates.
+	Partial    bool                   `json:",omitempty" yaml:"partial" toml:"partial"`
+	LeftDelim  string                 `json:",omitempty" yaml:"leftDelim" toml:"leftDelim"`
+	RightDelim string                 `json:",omitempty" yaml:"rightDelim" toml:"rightDelim"`
+	Vars       map[string]interface{} `json:",omitempty" yaml:"vars" toml:"vars"`
+	// Layout is the path of a layout file (relative to the template file) to
+	// render the template within, or "none" to use no layout.
+	Layout string `json:",omitempty" yaml:"layout" toml:"layout"`
+
+	layoutPath string
+}
+
+func (f *FrontMatter) mode() (os.FileMode, error) {
+	if f == nil || f.Mode == "" {
+		return 0, nil
+	}
+	return parseMode(f.Mode)
+}
+
+func (f *FrontMatter) delims(leftDelim, rightDelim string) (string, string) {
+	if f != nil && f.LeftDelim != "" {
+		leftDelim = f.LeftDelim
+	}
+	if f != nil && f.RightDelim != "" {
+		rightDelim = f.RightDelim
+	}
+	return leftDelim, rightDelim
+}
+
+// parseFrontMatter splits a template file into its front matter (nil if there
+// is none) and the number of lines the front matter takes up, and the
+// template text that follows it. Front matter is only read if it is enabled,
+// and a header that is not closed or does not decode into FrontMatter is kept
+// as template text, since it may as well be the start of a YAML document.
+func (t *Templates) parseFrontMatter(name string, data []byte) (*FrontMatter, int, string, error) {
+	if !t.ReadFrontMatter {
+		return nil, 0, string(data), nil
+	}
+	text := string(data)
+	lines := strings.SplitAfter(text, "\n")
+	var delimiter, format string
+	switch strings.TrimRight(lines[0], "\r\n") {
+	case "---":
+		delimiter, format = "---", FormatYAML
+	case "+++":
+		delimiter, format = "+++", FormatTOML
+	default:
+		return nil, 0, text, nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") != delimiter {
+			continue
+		}
+		// Line endings are only normalized in the header; the template text
+		// is kept as is.
+		header := []byte(strings.Replace(strings.Join(lines[1:i], ""), "\r\n", "\n", -1))
+		frontMatter := &FrontMatter{}
+		decoded := false
+		if format == FormatYAML {
+			decoded = yaml.UnmarshalStrict(header, frontMatter) == nil
+		} else {
+			meta, err := toml.Decode(string(header), frontMatter)
+			decoded = err == nil && len(meta.Undecoded()) == 0
+		}
+		if !decoded {
+			return nil, 0, text, nil
+		}
+		if frontMatter.Vars != nil {
+			frontMatter.Vars = flatten(frontMatter.Vars).(map[string]interface{})
+		}
+		if _, err := frontMatter.mode(); err != nil {
+			return nil, 0, "", fmt.Errorf("%s: invalid front matter: %v", name, err)
+		}
+		return frontMatter, i + 1, strings.Join(lines[i+1:], ""), nil
+	}
+	return nil, 0, text, nil
+}
+
+// frontMatterPlaceholder returns a template comment spanning the given number
+// of lines, which stands in for stripped front matter so that line numbers in
+// template errors stay the same.
+func frontMatterPlaceholder(lines int, leftDelim, rightDelim string) string {
+	if leftDelim == "" {
+		leftDelim = "{{"
+	}
+	if rightDelim == "" {
+		rightDelim = "}}"
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(leftDelim + "/*")
+	buf.WriteString(strings.Repeat("\n", lines))
+	buf.WriteString("*/" + rightDelim)
+	return buf.String()
+}
+
+// SaveFrontMatter writes the front matter of all templates that have any, by
+// template name, as JSON.
+func (t *Templates) SaveFrontMatter(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t.FrontMatter)
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		disabled    bool
+		frontMatter *FrontMatter
+		lines       int
+		text        string
+		err         string
+	}{
+		{
+			name:        "YAML",
+			data:        "---\noutput: a.txt\nmode: \"0755\"\nvars:\n  x: 1\n---\nbody\n",
+			frontMatter: &FrontMatter{Output: "a.txt", Mode: "0755", Vars: map[string]interface{}{"x": 1}},
+			lines:       6,
+			text:        "body\n",
+		},
+		{
+			name:        "TOML",
+			data:        "+++\npartial = true\nlayout = \"none\"\n+++\nbody",
+			frontMatter: &FrontMatter{Partial: true, Layout: "none"},
+			lines:       4,
+			text:        "body",
+		},
+		{
+			name:     "disabled",
+			data:     "---\noutput: a.txt\n---\nbody",
+			disabled: true,
+			text:     "---\noutput: a.txt\n---\nbody",
+		},
+		{
+			name: "no front matter",
+			data: "body\n---\n",
+			text: "body\n---\n",
+		},
+		{
+			name: "unclosed header",
+			data: "---\noutput: a.txt\nbody\n",
+			text: "---\noutput: a.txt\nbody\n",
+		},
+		{
+			name: "unknown YAML keys are kept as text",
+			data: "---\nname: x\n---\nname: y\n",
+			text: "---\nname: x\n---\nname: y\n",
+		},
+		{
+			name: "unknown TOML keys are kept as text",
+			data: "+++\nname = \"x\"\n+++\n",
+			text: "+++\nname = \"x\"\n+++\n",
+		},
+		{
+			name: "invalid YAML is kept as text",
+			data: "---\n[\n---\n",
+			text: "---\n[\n---\n",
+		},
+		{
+			name:        "CRLF is only normalized in the header",
+			data:        "---\r\noutput: a.txt\r\n---\r\nline 1\r\nline 2\r\n",
+			frontMatter: &FrontMatter{Output: "a.txt"},
+			lines:       3,
+			text:        "line 1\r\nline 2\r\n",
+		},
+		{
+			name: "invalid mode",
+			data: "---\nmode: rwx\n---\n",
+			err:  "invalid front matter",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			templates := &Templates{ReadFrontMatter: !test.disabled}
+			frontMatter, lines, text, err := templates.parseFrontMatter("test", []byte(test.data))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(frontMatter, test.frontMatter) {
+				t.Errorf("expected front matter %+v, got %+v", test.frontMatter, frontMatter)
+			}
+			if lines != test.lines {
+				t.Errorf("expected %d lines, got %d", test.lines, lines)
+			}
+			if text != test.text {
+				t.Errorf("expected text %q, got %q", test.text, text)
+			}
+		})
+	}
+}
+
+func TestFrontMatterPlaceholder(t *testing.T) {
+	for _, lines := range []int{0, 1, 5} {
+		for _, delims := range [][2]string{{"{{", "}}"}, {"[[", "]]"}} {
+			placeholder := frontMatterPlaceholder(lines, delims[0], delims[1])
+			if strings.Count(placeholder, "\n") != lines {
+				t.Errorf("expected %d line breaks, got %q", lines, placeholder)
+			}
+			text := placeholder + "x\n" + delims[0] + " if " + delims[1]
+			_, err := template.New("test").Delims(delims[0], delims[1]).Parse(text)
+			if expected := fmt.Sprintf("test:%d:", lines+2); err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected a parse error at %q, got %v", expected, err)
+			}
+		}
+	}
+	if placeholder := frontMatterPlaceholder(1, "", ""); placeholder != "{{/*\n*/}}" {
+		t.Errorf("expected the default delimiters, got %q", placeholder)
+	}
+}
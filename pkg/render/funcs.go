@@ -62,11 +62,38 @@ var renderFuncs = template.FuncMap{
 		err := yaml.Unmarshal([]byte(value), &obj)
 		return obj, err
 	},
+	"toYAMLAll": func(value interface{}) (string, error) {
+		return formatYAMLDocuments(asEmptyInterfaceSlice(value))
+	},
+	"fromYAMLAll": func(value string) ([]interface{}, error) {
+		return parseYAMLDocuments("fromYAMLAll", []byte(value))
+	},
 	"fromTOML": func(value string) (interface{}, error) {
 		var obj interface{}
 		err := toml.Unmarshal([]byte(value), &obj)
 		return obj, err
 	},
+	"toDotenv": func(value map[string]interface{}) (string, error) {
+		return formatDotenv(value)
+	},
+	"fromDotenv": func(value string) (map[string]interface{}, error) {
+		return parseDotenv([]byte(value), nil)
+	},
+	"toProperties": func(value map[string]interface{}) (string, error) {
+		return formatProperties(value)
+	},
+	"fromProperties": func(value string) (map[string]interface{}, error) {
+		return parseProperties([]byte(value))
+	},
+	"file": func(name string) (string, error) {
+		return "", errFileUnavailable
+	},
+	"include": func(name string, vars interface{}) (string, error) {
+		return "", errIncludeUnavailable
+	},
+	"tpl": func(text string, vars interface{}) (string, error) {
+		return "", errIncludeUnavailable
+	},
 	"set": func(dict map[string]interface{}, kvs ...interface{}) map[string]interface{} {
 		for i := 0; i < len(kvs); i += 2 {
 			dict[kvs[i].(string)] = kvs[i+1]
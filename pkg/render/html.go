@@ -0,0 +1,74 @@
+package render
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Template modes: templates are rendered as plain text, as HTML (with
+// contextual auto-escaping), or as HTML if their name ends in .html or .htm
+// (optionally followed by one of TemplateSuffixes).
+const (
+	TemplateModeText = "text"
+	TemplateModeHTML = "html"
+	TemplateModeAuto = "auto"
+)
+
+// TemplateModes are the valid values of Config.TemplateMode.
+var TemplateModes = []string{TemplateModeText, TemplateModeHTML, TemplateModeAuto}
+
+func validateTemplateMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, valid := range TemplateModes {
+		if mode == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown template mode %q (expected one of %s)", mode, strings.Join(TemplateModes, ", "))
+}
+
+// isHTML reports whether the named template is rendered in HTML mode.
+func (t *Templates) isHTML(name string) bool {
+	switch t.Mode {
+	case TemplateModeHTML:
+		return true
+	case TemplateModeAuto:
+		for _, suffix := range TemplateSuffixes {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		ext := strings.ToLower(path.Ext(name))
+		return ext == ".html" || ext == ".htm"
+	}
+	return false
+}
+
+// toHTML returns an html/template equivalent of tmpl, made from copies of
+// the parse trees of tmpl and all templates associated with it, with the
+// given functions added to the template functions.
+func (t *Templates) toHTML(tmpl *template.Template, funcs template.FuncMap) (*htmltemplate.Template, error) {
+	set := htmltemplate.New("html").Funcs(htmltemplate.FuncMap(t.Funcs)).Funcs(htmltemplate.FuncMap(funcs))
+	if t.Strict {
+		set.Option("missingkey=error")
+	} else {
+		set.Option("missingkey=zero")
+	}
+	for _, associated := range tmpl.Templates() {
+		if associated.Tree == nil {
+			continue
+		}
+		_, err := set.AddParseTree(associated.Name(), associated.Tree.Copy())
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := set.Lookup(tmpl.Name())
+	if result == nil {
+		return nil, fmt.Errorf("no such template: %q", tmpl.Name())
+	}
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHTMLMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		nameTexts []string
+		outputs   map[string]string
+	}{
+		{
+			name:      "escaping",
+			nameTexts: []string{"a.html", `<p title="{{ .x }}">{{ .x }}</p>`},
+			outputs:   map[string]string{"a.html": `<p title="&lt;b&gt;">&lt;b&gt;</p>`},
+		},
+		{
+			name:      "include",
+			nameTexts: []string{"a.html", `<div>{{ include "part" . }}</div>`, "part", `<em>{{ .x }}</em>`},
+			outputs:   map[string]string{"a.html": `<div><em>&lt;b&gt;</em></div>`},
+		},
+		{
+			name:      "tpl",
+			nameTexts: []string{"a.html", `<div>{{ tpl "<em>{{ .x }}</em>" . }}</div>`},
+			outputs:   map[string]string{"a.html": `<div><em>&lt;b&gt;</em></div>`},
+		},
+		{
+			name:      "tpl calling a named template",
+			nameTexts: []string{"a.html", `<div>{{ tpl "{{ template \"part\" . }}" . }}</div>`, "part", `<em>{{ .x }}</em>`},
+			outputs:   map[string]string{"a.html": `<div><em>&lt;b&gt;</em></div>`},
+		},
+		{
+			name:      "nested include",
+			nameTexts: []string{"a.html", `{{ include "outer" . }}`, "outer", `<div>{{ include "part" . }}</div>`, "part", `<em>{{ .x }}</em>`},
+			outputs:   map[string]string{"a.html": `<div><em>&lt;b&gt;</em></div>`},
+		},
+		{
+			name:      "file",
+			nameTexts: []string{"a.html", `{{ file "x.html" }}<p>{{ .x }}</p>{{ file "y.html" }}<a href="?q={{ .x }}">y</a>`},
+			outputs:   map[string]string{"x.html": `<p>&lt;b&gt;</p>`, "y.html": `<a href="?q=%3cb%3e">y</a>`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{TemplateMode: TemplateModeHTML, TemplateOutExclude: "{outer,part}"}
+			templates := parameterTemplates(t, config, test.nameTexts...)
+			templates.Vars = map[string]interface{}{"x": "<b>"}
+			contents := renderedOutputs(t, templates)
+			if !reflect.DeepEqual(contents, test.outputs) {
+				t.Errorf("expected %q, got %q", test.outputs, contents)
+			}
+		})
+	}
+}
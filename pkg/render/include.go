@@ -0,0 +1,200 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+)
+
+// MaxIncludeDepth bounds how deeply calls to include and tpl may nest.
+var MaxIncludeDepth = 100
+
+var errIncludeUnavailable = errors.New("include and tpl are only available in templates loaded by Templates.FromConfig")
+
+// IncludeDepthError is returned when calls to include and tpl nest more
+// deeply than MaxIncludeDepth.
+type IncludeDepthError struct {
+	Call string
+}
+
+func (e *IncludeDepthError) Error() string {
+	return fmt.Sprintf("%s: maximum nesting depth of include and tpl (%d) exceeded; is a template including itself?", e.Call, MaxIncludeDepth)
+}
+
+// execution is the state of one execution of an output template, or of a
+// chain of nested calls to include and tpl. The templates executed are a clone
+// of the output template's set whose include, tpl and file functions are bound
+// to the execution, so that concurrent executions do not share any state. The
+// set is bound once per execution and reused by nested calls.
+type execution struct {
+	t        *Templates
+	set      *template.Template
+	htmlSet  *htmltemplate.Template
+	tplSet   *template.Template
+	tmpl     executable
+	html     bool
+	depth    int
+	depthErr *IncludeDepthError
+	out      *countingWriter
+	files    []fileStart
+}
+
+// newExecution returns an execution of the named template's output template.
+func (t *Templates) newExecution(name string) (*execution, error) {
+	tmpl, err := t.outputTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	e := &execution{t: t, html: t.isHTML(name)}
+	err = e.bind(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	e.tmpl = e.set.Lookup(tmpl.Name())
+	if e.html {
+		e.tmpl = e.htmlSet.Lookup(tmpl.Name())
+	}
+	return e, nil
+}
+
+// bind makes the set of templates of the execution from a clone of tmpl and
+// its associated templates, with the include, tpl and file functions bound to
+// the execution. In HTML mode, the set is also converted to HTML templates.
+// For pages, tmpl is the set into which the page and its layout have been
+// parsed, so that include resolves templates defined by the page.
+func (e *execution) bind(tmpl *template.Template) error {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	e.set = clone.Funcs(e.funcs())
+	if e.html {
+		e.htmlSet, err = e.t.toHTML(e.set, e.funcs())
+	}
+	return err
+}
+
+// execute executes the output template with the given variables to w, and
+// returns the files started by the file function.
+func (e *execution) execute(vars interface{}, w io.Writer) ([]fileStart, error) {
+	e.out = &countingWriter{w: w}
+	e.files = nil
+	defer func() { e.out = nil }()
+	err := e.t.execute(e.tmpl, vars, e.out)
+	return e.files, err
+}
+
+// includeFuncs returns the include and tpl functions, which render a named
+// template or a string as a template, respectively, and return the result.
+// Each call starts a new execution.
+func (t *Templates) includeFuncs() template.FuncMap {
+	return template.FuncMap{
+		"include": func(name string, vars interface{}) (string, error) {
+			return (&execution{t: t}).include(name, vars)
+		},
+		"tpl": func(text string, vars interface{}) (string, error) {
+			return (&execution{t: t}).tpl(text, vars)
+		},
+	}
+}
+
+// funcs returns the include, tpl and file functions bound to the execution.
+// In HTML mode, include and tpl return HTML, since the result has been
+// escaped already.
+func (e *execution) funcs() template.FuncMap {
+	if e.html {
+		return template.FuncMap{
+			"include": func(name string, vars interface{}) (htmltemplate.HTML, error) {
+				result, err := e.include(name, vars)
+				return htmltemplate.HTML(result), err
+			},
+			"tpl": func(text string, vars interface{}) (htmltemplate.HTML, error) {
+				result, err := e.tpl(text, vars)
+				return htmltemplate.HTML(result), err
+			},
+			"file": e.file,
+		}
+	}
+	return template.FuncMap{
+		"include": e.include,
+		"tpl":     e.tpl,
+		"file":    e.file,
+	}
+}
+
+// bindRoot binds the root template set for executions started by the include
+// and tpl functions of Templates.Funcs.
+func (e *execution) bindRoot() error {
+	if e.set != nil {
+		return nil
+	}
+	return e.bind(e.t.Root)
+}
+
+func (e *execution) include(name string, vars interface{}) (string, error) {
+	err := e.bindRoot()
+	if err != nil {
+		return "", err
+	}
+	var tmpl executable
+	if e.html {
+		if html := e.htmlSet.Lookup(name); html != nil {
+			tmpl = html
+		}
+	} else if text := e.set.Lookup(name); text != nil {
+		tmpl = text
+	}
+	if tmpl == nil || e.t.Assets[name] != nil {
+		return "", fmt.Errorf("include: no such template: %q", name)
+	}
+	return e.run(fmt.Sprintf("include %q", name), tmpl, vars)
+}
+
+// tpl parses text into a clone of the execution's template set, so that it
+// may refer to the templates of the set by name. The clone is made once per
+// execution; templates defined by text are visible to later calls of tpl.
+func (e *execution) tpl(text string, vars interface{}) (string, error) {
+	err := e.bindRoot()
+	if err != nil {
+		return "", err
+	}
+	if e.tplSet == nil {
+		e.tplSet, err = e.set.Clone()
+		if err != nil {
+			return "", err
+		}
+	}
+	tmpl, err := e.tplSet.New("tpl").Delims(e.t.LeftDelim, e.t.RightDelim).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	if !e.html {
+		return e.run("tpl", tmpl, vars)
+	}
+	html, err := e.t.toHTML(tmpl, e.funcs())
+	if err != nil {
+		return "", err
+	}
+	return e.run("tpl", html, vars)
+}
+
+// run executes tmpl one level deeper in the execution. Once the maximum depth
+// is exceeded, the resulting error is returned by every level as is, so that
+// it is reported once instead of once per level.
+func (e *execution) run(call string, tmpl executable, vars interface{}) (string, error) {
+	if e.depth >= MaxIncludeDepth {
+		e.depthErr = &IncludeDepthError{Call: call}
+		return "", e.depthErr
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+	buf := &bytes.Buffer{}
+	err := e.t.execute(tmpl, vars, buf)
+	if err != nil && e.depthErr != nil {
+		return "", e.depthErr
+	}
+	return buf.String(), err
+}
@@ -0,0 +1,154 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// addParameterSources adds a template source to config for each of the given
+// name/text pairs.
+func addParameterSources(config *Config, nameTexts ...string) {
+	for i := 0; i < len(nameTexts); i += 2 {
+		config.TemplateSources = append(config.TemplateSources, &TemplateSource{
+			Name:          nameTexts[i],
+			FromParameter: &TemplateSourceParameter{Value: nameTexts[i+1]},
+		})
+	}
+}
+
+// loadParameterTemplates returns templates loaded from the given name/text
+// pairs.
+func loadParameterTemplates(config *Config, nameTexts ...string) (*Templates, error) {
+	addParameterSources(config, nameTexts...)
+	templates := &Templates{Vars: map[string]interface{}{}}
+	err := templates.FromConfig(Funcs(), config)
+	return templates, err
+}
+
+// parameterTemplates is like loadParameterTemplates, but fails the test on
+// errors.
+func parameterTemplates(t *testing.T, config *Config, nameTexts ...string) *Templates {
+	templates, err := loadParameterTemplates(config, nameTexts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return templates
+}
+
+// renderedOutputs renders templates into memory and returns the content of
+// each output by name.
+func renderedOutputs(t *testing.T, templates *Templates) map[string]string {
+	outputs, err := templates.outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, output := range outputs {
+		contents[output.Name] = string(output.Content)
+	}
+	return contents
+}
+
+func TestIncludeDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		err   bool
+	}{
+		{"below the limit", MaxIncludeDepth - 1, false},
+		{"at the limit", MaxIncludeDepth, false},
+		{"above the limit", MaxIncludeDepth + 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nameTexts := []string{"out", `{{ include "level1" . }}`}
+			for i := 1; i < test.depth; i++ {
+				nameTexts = append(nameTexts, fmt.Sprintf("level%d", i), fmt.Sprintf(`{{ include "level%d" . }}`, i+1))
+			}
+			nameTexts = append(nameTexts, fmt.Sprintf("level%d", test.depth), "done")
+			templates := parameterTemplates(t, &Config{TemplateOutExclude: "level*"}, nameTexts...)
+			buf := &bytes.Buffer{}
+			err := templates.Execute("out", templates.Vars, buf)
+			if test.err {
+				if err == nil || !strings.Contains(err.Error(), "maximum nesting depth") {
+					t.Fatalf("expected the nesting depth to be exceeded, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != "done" {
+				t.Errorf("expected %q, got %q", "done", buf.String())
+			}
+		})
+	}
+}
+
+func TestIncludeSelf(t *testing.T) {
+	templates := parameterTemplates(t, &Config{}, "loop", `{{ include "loop" . }}`)
+	err := templates.Execute("loop", templates.Vars, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Count(err.Error(), "maximum nesting depth") != 1 {
+		t.Errorf("expected the error to be reported once, got %q", err)
+	}
+}
+
+func TestIncludeConcurrent(t *testing.T) {
+	nameTexts := []string{"out", `{{ include "level1" . }}`}
+	for i := 1; i < MaxIncludeDepth/2; i++ {
+		nameTexts = append(nameTexts, fmt.Sprintf("level%d", i), fmt.Sprintf(`{{ include "level%d" . }}`, i+1))
+	}
+	nameTexts = append(nameTexts, fmt.Sprintf("level%d", MaxIncludeDepth/2), `{{ tpl "done" . }}`)
+	templates := parameterTemplates(t, &Config{TemplateOutExclude: "level*"}, nameTexts...)
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 16*20)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				errs <- templates.Execute("out", templates.Vars, &bytes.Buffer{})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTpl(t *testing.T) {
+	tests := []struct {
+		name      string
+		nameTexts []string
+		output    string
+	}{
+		{"text", []string{"out", `{{ tpl "{{ .x }}" . }}`}, "x"},
+		{"named template", []string{"out", `{{ tpl "{{ template \"h\" . }}" . }}`, "h", "h{{ .x }}"}, "hx"},
+		{"include", []string{"out", `{{ tpl "{{ include \"h\" . | upper }}" . }}`, "h", "h{{ .x }}"}, "HX"},
+		{"nested", []string{"out", `{{ tpl "{{ tpl \"{{ .x }}\" . }}{{ .x }}" . }}`}, "xx"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			templates := parameterTemplates(t, &Config{TemplateOutExclude: "h"}, test.nameTexts...)
+			templates.Vars = map[string]interface{}{"x": "x"}
+			buf := &bytes.Buffer{}
+			err := templates.Execute("out", templates.Vars, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.output {
+				t.Errorf("expected %q, got %q", test.output, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package render
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// LayoutNone in a template's front matter disables the default layout.
+const LayoutNone = "none"
+
+// page is an output template that is rendered within a layout. Pages are not
+// parsed into the shared root template, so that each page's definitions only
+// override the layout's blocks for that page.
+type page struct {
+	Text       string
+	LeftDelim  string
+	RightDelim string
+	Layout     string
+}
+
+// layoutOf returns the path of the layout file for the named template, or ""
+// if it does not use a layout.
+func (t *Templates) layoutOf(name string, frontMatter *FrontMatter) string {
+	if frontMatter != nil && frontMatter.Layout == LayoutNone {
+		return ""
+	}
+	if frontMatter != nil && frontMatter.layoutPath != "" {
+		return frontMatter.layoutPath
+	}
+	return t.Layout
+}
+
+// pageLayout returns the path of the layout file the named template is
+// rendered within, or "" if it is not rendered within a layout. A layout given
+// in the template's front matter is always used, and the default layout is
+// used for templates that define templates (such as the layout's blocks). The
+// content of a template outside its definitions is not rendered within the
+// layout, so it is an error for such a template to have any.
+func (t *Templates) pageLayout(name, text, leftDelim, rightDelim string, frontMatter *FrontMatter) (string, error) {
+	layout := t.layoutOf(name, frontMatter)
+	if layout == "" || t.partial(name) {
+		return "", nil
+	}
+	treeSet := map[string]*parse.Tree{}
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	_, err := tree.Parse(text, leftDelim, rightDelim, treeSet)
+	if err != nil {
+		// Reported when the template itself is parsed.
+		return "", nil
+	}
+	defines := false
+	for definedName := range treeSet {
+		defines = defines || definedName != name
+	}
+	if !defines && (frontMatter == nil || frontMatter.layoutPath == "") {
+		return "", nil
+	}
+	if !parse.IsEmptyTree(tree.Root) {
+		return "", t.hook.invalid(name, fmt.Errorf("template %q is rendered within the layout %q, but has content outside of define, which would not be rendered", name, layout))
+	}
+	return layout, nil
+}
+
+// loadLayout reads the layout file at the given path, unless it was read before.
+func (t *Templates) loadLayout(path string) error {
+	if _, ok := t.layouts[path]; ok {
+		return nil
+	}
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	frontMatter, frontMatterLines, text, err := t.parseFrontMatter(path, bytes)
+	if err != nil {
+		return err
+	}
+	if frontMatter != nil {
+		text = frontMatterPlaceholder(frontMatterLines, t.LeftDelim, t.RightDelim) + text
+	}
+	err = t.hook.layout(t, path, text)
+	if err != nil {
+		return err
+	}
+	t.layouts[path] = text
+	return nil
+}
+
+// LayoutPaths returns the paths of the layout files read by the templates,
+// including those chosen in front matter.
+func (t *Templates) LayoutPaths() []string {
+	paths := make([]string, 0, len(t.layouts))
+	for path := range t.layouts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// definePage records the named template as a page rendered within the given
+// layout, after checking that it parses.
+func (t *Templates) definePage(name, text, leftDelim, rightDelim, layout string) error {
+	err := t.loadLayout(layout)
+	if err != nil {
+		return err
+	}
+	_, err = template.New(name).Delims(leftDelim, rightDelim).Funcs(t.Funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+	t.pages[name] = &page{
+		Text:       text,
+		LeftDelim:  leftDelim,
+		RightDelim: rightDelim,
+		Layout:     layout,
+	}
+	return nil
+}
+
+// outputTemplate returns the template to execute to render the named
+// template. For pages, this is the page's layout in a clone of the root
+// template into which the page has been parsed.
+func (t *Templates) outputTemplate(name string) (*template.Template, error) {
+	p := t.pages[name]
+	if p == nil {
+		tmpl := t.Root.Lookup(name)
+		if tmpl == nil {
+			return nil, fmt.Errorf("no such template: %q", name)
+		}
+		return tmpl, nil
+	}
+	clone, err := t.Root.Clone()
+	if err != nil {
+		return nil, err
+	}
+	layout, err := clone.New(p.Layout).Parse(t.layouts[p.Layout])
+	if err != nil {
+		return nil, err
+	}
+	_, err = clone.New(name).Delims(p.LeftDelim, p.RightDelim).Parse(p.Text)
+	if err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// resolveLayout makes a layout path given in the front matter of the
+// template file at templatePath relative to the directory of that file.
+func (f *FrontMatter) resolveLayout(templatePath string) {
+	if f == nil || f.Layout == "" || f.Layout == LayoutNone {
+		return
+	}
+	f.layoutPath = f.Layout
+	if !filepath.IsAbs(f.Layout) {
+		f.layoutPath = filepath.Join(filepath.Dir(templatePath), f.Layout)
+	}
+}
@@ -0,0 +1,80 @@
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name      string
+		layout    string
+		nameTexts []string
+		outputs   map[string]string
+		err       string
+	}{
+		{
+			name:      "pages",
+			nameTexts: []string{"a", `{{ define "body" }}a{{ end }}`, "b", "\n{{ define \"body\" }}b{{ end }}\n"},
+			outputs:   map[string]string{"a": "<html>a</html>", "b": "<html>b</html>"},
+		},
+		{
+			name:      "template without definitions",
+			nameTexts: []string{"run.sh", "echo {{ .x }}"},
+			outputs:   map[string]string{"run.sh": "echo x"},
+		},
+		{
+			name:      "layout calling a function",
+			layout:    `<html>{{ "x" | upper }}{{ block "body" . }}{{ end }}</html>`,
+			nameTexts: []string{"a", `{{ define "body" }}a{{ end }}`},
+			outputs:   map[string]string{"a": "<html>Xa</html>"},
+		},
+		{
+			name:      "layout including a page's template",
+			layout:    `<html>{{ include "body" . | upper }}</html>{{ define "body" }}default{{ end }}`,
+			nameTexts: []string{"a", `{{ define "body" }}a{{ end }}`, "b", `{{ define "title" }}b{{ end }}`},
+			outputs:   map[string]string{"a": "<html>A</html>", "b": "<html>DEFAULT</html>"},
+		},
+		{
+			name:      "content outside of define",
+			nameTexts: []string{"a", `a{{ define "body" }}a{{ end }}`},
+			err:       "has content outside of define",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.layout == "" {
+				test.layout = `<html>{{ block "body" . }}default{{ end }}</html>`
+			}
+			layout := filepath.Join(dir, "layout.html")
+			err := ioutil.WriteFile(layout, []byte(test.layout), 0666)
+			if err != nil {
+				t.Fatal(err)
+			}
+			templates, err := loadParameterTemplates(&Config{TemplateLayout: layout}, test.nameTexts...)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			templates.Vars = map[string]interface{}{"x": "x"}
+			contents := renderedOutputs(t, templates)
+			if !reflect.DeepEqual(contents, test.outputs) {
+				t.Errorf("expected %q, got %q", test.outputs, contents)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Kinds of lint issues.
+const (
+	LintParse    = "parse"
+	LintFunction = "function"
+	LintTemplate = "template"
+	LintField    = "field"
+)
+
+// templateBuiltins are the functions predefined by text/template.
+var templateBuiltins = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or",
+	"print", "printf", "println", "urlquery",
+	"eq", "ge", "gt", "le", "lt", "ne",
+}
+
+// LintIssue is a problem found in a template without executing it.
+type LintIssue struct {
+	File     string
+	Template string `json:",omitempty"`
+	Line     int    `json:",omitempty"`
+	Column   int    `json:",omitempty"`
+	Kind     string
+	Message  string
+}
+
+func (i *LintIssue) String() string {
+	position := i.File
+	if i.Line > 0 {
+		position += fmt.Sprintf(":%d", i.Line)
+		if i.Column > 0 {
+			position += fmt.Sprintf(":%d", i.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s", position, i.Message)
+}
+
+// LintReport lists the issues found in a set of templates.
+type LintReport struct {
+	Templates int
+	Issues    []*LintIssue
+}
+
+// Save writes the report as JSON.
+func (r *LintReport) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Print writes one line per issue.
+func (r *LintReport) Print(w io.Writer) error {
+	for _, issue := range r.Issues {
+		_, err := fmt.Fprintln(w, issue)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lintSource is a template recorded (instead of parsed) in lint mode.
+type lintSource struct {
+	Name       string
+	Text       string
+	LeftDelim  string
+	RightDelim string
+	Layout     bool
+}
+
+// linter is the loadHook of lint mode. It records templates and layouts
+// instead of parsing them, and problems found while loading as issues.
+type linter struct {
+	sources []*lintSource
+	issues  []*LintIssue
+}
+
+func (l *linter) template(t *Templates, name, text, leftDelim, rightDelim, layout string) error {
+	l.sources = append(l.sources, &lintSource{
+		Name:       name,
+		Text:       text,
+		LeftDelim:  leftDelim,
+		RightDelim: rightDelim,
+	})
+	if layout != "" {
+		t.pages[name] = &page{Layout: layout}
+		return t.loadLayout(layout)
+	}
+	return nil
+}
+
+func (l *linter) layout(t *Templates, path, text string) error {
+	l.sources = append(l.sources, &lintSource{
+		Name:       path,
+		Text:       text,
+		LeftDelim:  t.LeftDelim,
+		RightDelim: t.RightDelim,
+		Layout:     true,
+	})
+	return nil
+}
+
+func (l *linter) invalid(name string, err error) error {
+	l.issues = append(l.issues, &LintIssue{Template: name, Kind: LintTemplate, Message: err.Error()})
+	return nil
+}
+
+// Lint loads the templates given by config without parsing them as usual,
+// and then checks them for parse errors, calls to unknown functions, and
+// references to undefined templates. If checkFields is set, it also reports
+// fields that are not defined in vars, as far as that can be determined
+// without executing the templates.
+func Lint(funcs template.FuncMap, config *Config, vars Vars, checkFields bool) (*LintReport, error) {
+	l := &linter{}
+	t := &Templates{Vars: vars, hook: l}
+	err := t.FromConfig(funcs, config)
+	if err != nil {
+		if parseError, ok := err.(*ParseError); ok {
+			return &LintReport{Issues: []*LintIssue{{
+				File:    parseError.Name,
+				Line:    parseError.Line,
+				Column:  parseError.Column,
+				Kind:    LintParse,
+				Message: fmt.Sprintf("invalid %s: %v", strings.ToUpper(parseError.Format), parseError.Err),
+			}}}, nil
+		}
+		return nil, err
+	}
+	return l.run(t, checkFields), nil
+}
+
+var parseErrorPattern = regexp.MustCompile(`^template: (.*?):(\d+):(?:(\d+):)? (.*)$`)
+
+func (l *linter) run(t *Templates, checkFields bool) *LintReport {
+	report := &LintReport{Templates: len(l.sources), Issues: []*LintIssue{}}
+	file := func(name string) string {
+		if path, ok := t.sources[name]; ok {
+			return path
+		}
+		return name
+	}
+	for _, issue := range l.issues {
+		issue.File = file(issue.Template)
+		report.Issues = append(report.Issues, issue)
+	}
+	trees := map[string]*parse.Tree{}
+	sourceTrees := map[string]*parse.Tree{}
+	allTrees := []*parse.Tree{}
+	for _, source := range l.sources {
+		treeSet := map[string]*parse.Tree{}
+		tree := parse.New(source.Name)
+		tree.Mode = parse.SkipFuncCheck
+		_, err := tree.Parse(source.Text, source.LeftDelim, source.RightDelim, treeSet)
+		if err != nil {
+			issue := &LintIssue{File: file(source.Name), Template: source.Name, Kind: LintParse, Message: err.Error()}
+			if match := parseErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+				issue.Line, _ = strconv.Atoi(match[2])
+				issue.Column, _ = strconv.Atoi(match[3])
+				issue.Message = match[4]
+			}
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+		names := make([]string, 0, len(treeSet))
+		for name := range treeSet {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			trees[name] = treeSet[name]
+			allTrees = append(allTrees, treeSet[name])
+		}
+		sourceTrees[source.Name] = treeSet[source.Name]
+	}
+	known := map[string]bool{}
+	for name := range t.Funcs {
+		known[name] = true
+	}
+	for _, name := range templateBuiltins {
+		known[name] = true
+	}
+	for _, tree := range allTrees {
+		if tree.Root == nil {
+			continue
+		}
+		w := &lintWalker{tree: tree, file: file(tree.ParseName), known: known, trees: trees}
+		var dot interface{}
+		dotKnown := false
+		if checkFields && tree == sourceTrees[tree.ParseName] {
+			source := l.source(tree.ParseName)
+			output := !t.partial(source.Name) && t.ForEach[source.Name] == nil && t.pages[source.Name] == nil
+			if source.Layout || output {
+				dot, dotKnown = map[string]interface{}(t.Vars), true
+				if !source.Layout {
+					dot = t.templateVars(source.Name)
+				}
+			}
+		}
+		w.root, w.rootKnown = dot, dotKnown
+		w.walk(tree.Root, dot, dotKnown)
+		report.Issues = append(report.Issues, w.issues...)
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return report
+}
+
+func (l *linter) source(name string) *lintSource {
+	for _, source := range l.sources {
+		if source.Name == name {
+			return source
+		}
+	}
+	return &lintSource{Name: name}
+}
+
+type lintWalker struct {
+	tree      *parse.Tree
+	file      string
+	known     map[string]bool
+	trees     map[string]*parse.Tree
+	root      interface{}
+	rootKnown bool
+	issues    []*LintIssue
+}
+
+func (w *lintWalker) report(node parse.Node, kind, format string, args ...interface{}) {
+	location, _ := w.tree.ErrorContext(node)
+	issue := &LintIssue{File: w.file, Template: w.tree.Name, Kind: kind, Message: fmt.Sprintf(format, args...)}
+	parts := strings.Split(location, ":")
+	if len(parts) >= 3 {
+		issue.Line, _ = strconv.Atoi(parts[len(parts)-2])
+		issue.Column, _ = strconv.Atoi(parts[len(parts)-1])
+	}
+	w.issues = append(w.issues, issue)
+}
+
+// walk checks node, where dot is the value of "." if dotKnown is set.
+func (w *lintWalker) walk(node parse.Node, dot interface{}, dotKnown bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			w.walk(child, dot, dotKnown)
+		}
+	case *parse.ActionNode:
+		w.walk(node.Pipe, dot, dotKnown)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, cmd := range node.Cmds {
+			w.walk(cmd, dot, dotKnown)
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			w.walk(arg, dot, dotKnown)
+		}
+	case *parse.IdentifierNode:
+		if !w.known[node.Ident] {
+			w.report(node, LintFunction, "function %q not defined", node.Ident)
+		}
+	case *parse.FieldNode:
+		if dotKnown {
+			w.checkFields(node, ".", dot, node.Ident)
+		}
+	case *parse.VariableNode:
+		if node.Ident[0] == "$" && len(node.Ident) > 1 && w.rootKnown {
+			w.checkFields(node, "$.", w.root, node.Ident[1:])
+		}
+	case *parse.ChainNode:
+		w.walk(node.Node, dot, dotKnown)
+	case *parse.IfNode:
+		w.walk(node.Pipe, dot, dotKnown)
+		w.walk(node.List, dot, dotKnown)
+		w.walk(node.ElseList, dot, dotKnown)
+	case *parse.WithNode:
+		w.walk(node.Pipe, dot, dotKnown)
+		inner, innerKnown := w.pipeValue(node.Pipe, dot, dotKnown)
+		w.walk(node.List, inner, innerKnown)
+		w.walk(node.ElseList, dot, dotKnown)
+	case *parse.RangeNode:
+		w.walk(node.Pipe, dot, dotKnown)
+		w.walk(node.List, nil, false)
+		w.walk(node.ElseList, dot, dotKnown)
+	case *parse.TemplateNode:
+		if w.trees[node.Name] == nil {
+			w.report(node, LintTemplate, "template %q not defined", node.Name)
+		}
+		w.walk(node.Pipe, dot, dotKnown)
+	}
+}
+
+// pipeValue returns the value of a pipeline consisting of a single field,
+// as far as it is known.
+func (w *lintWalker) pipeValue(pipe *parse.PipeNode, dot interface{}, dotKnown bool) (interface{}, bool) {
+	if !dotKnown || pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return nil, false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return dot, true
+	case *parse.FieldNode:
+		return lookupFields(dot, arg.Ident)
+	}
+	return nil, false
+}
+
+// checkFields reports the first of the given fields that is not defined.
+func (w *lintWalker) checkFields(node parse.Node, prefix string, value interface{}, fields []string) {
+	for i, field := range fields {
+		if hasMethod(value, field) {
+			return
+		}
+		m, ok := asMap(value)
+		if !ok {
+			return
+		}
+		value, ok = m[field]
+		if !ok {
+			w.report(node, LintField, "variable %s%s not defined", prefix, strings.Join(fields[:i+1], "."))
+			return
+		}
+	}
+}
+
+// lookupFields returns the value at the given fields below value, if known.
+func lookupFields(value interface{}, fields []string) (interface{}, bool) {
+	for _, field := range fields {
+		m, ok := asMap(value)
+		if !ok || hasMethod(value, field) {
+			return nil, false
+		}
+		value, ok = m[field]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func hasMethod(value interface{}, name string) bool {
+	return value != nil && reflect.ValueOf(value).MethodByName(name).IsValid()
+}
@@ -0,0 +1,165 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// lintParameters lints templates given as name/text pairs.
+func lintParameters(t *testing.T, config *Config, vars Vars, nameTexts ...string) *LintReport {
+	addParameterSources(config, nameTexts...)
+	report, err := Lint(Funcs(), config, vars, vars != nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestLint(t *testing.T) {
+	vars := Vars{"a": map[string]interface{}{"b": 1}, "l": []interface{}{1}}
+	tests := []struct {
+		name      string
+		nameTexts []string
+		vars      Vars
+		issues    []LintIssue
+	}{
+		{
+			name:      "no issues",
+			nameTexts: []string{"a", `{{ .a.b | toJSON }}{{ template "b" . }}`, "b", `{{ len .l }}`},
+			vars:      vars,
+		},
+		{
+			name:      "unknown function",
+			nameTexts: []string{"a", "x\n{{ nope . }}"},
+			issues:    []LintIssue{{File: "a", Template: "a", Line: 2, Column: 3, Kind: LintFunction, Message: `function "nope" not defined`}},
+		},
+		{
+			name:      "undefined template",
+			nameTexts: []string{"a", `{{ template "b" . }}{{ define "c" }}{{ end }}{{ template "c" }}`},
+			issues:    []LintIssue{{File: "a", Template: "a", Line: 1, Column: 12, Kind: LintTemplate, Message: `template "b" not defined`}},
+		},
+		{
+			name:      "parse error",
+			nameTexts: []string{"a", "x\n{{ if }}"},
+			issues:    []LintIssue{{File: "a", Template: "a", Line: 2, Kind: LintParse, Message: "missing value for if"}},
+		},
+		{
+			name:      "fields",
+			nameTexts: []string{"a", `{{ .a.b }}{{ .a.c }}{{ .x }}`},
+			vars:      vars,
+			issues: []LintIssue{
+				{File: "a", Template: "a", Line: 1, Column: 15, Kind: LintField, Message: "variable .a.c not defined"},
+				{File: "a", Template: "a", Line: 1, Column: 23, Kind: LintField, Message: "variable .x not defined"},
+			},
+		},
+		{
+			name:      "fields under with",
+			nameTexts: []string{"a", `{{ with .a }}{{ .b }}{{ .c }}{{ else }}{{ .d }}{{ end }}`},
+			vars:      vars,
+			issues: []LintIssue{
+				{File: "a", Template: "a", Line: 1, Column: 24, Kind: LintField, Message: "variable .c not defined"},
+				{File: "a", Template: "a", Line: 1, Column: 42, Kind: LintField, Message: "variable .d not defined"},
+			},
+		},
+		{
+			name:      "fields under range and $",
+			nameTexts: []string{"a", `{{ range .l }}{{ .anything }}{{ $.a.b }}{{ $.y }}{{ end }}`},
+			vars:      vars,
+			issues:    []LintIssue{{File: "a", Template: "a", Line: 1, Column: 44, Kind: LintField, Message: "variable $.y not defined"}},
+		},
+		{
+			name:      "fields are not checked without variables",
+			nameTexts: []string{"a", `{{ .x }}`},
+		},
+		{
+			name:      "fields are not checked in partials",
+			nameTexts: []string{"_a", `{{ .x }}`},
+			vars:      vars,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := lintParameters(t, &Config{TemplateOutExclude: "_*"}, test.vars, test.nameTexts...)
+			issues := []LintIssue{}
+			for _, issue := range report.Issues {
+				issues = append(issues, *issue)
+			}
+			if test.issues == nil {
+				test.issues = []LintIssue{}
+			}
+			if !reflect.DeepEqual(issues, test.issues) {
+				t.Errorf("expected %+v, got %+v", test.issues, issues)
+			}
+		})
+	}
+}
+
+func TestLintLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layout := filepath.Join(dir, "layout.html")
+	err = ioutil.WriteFile(layout, []byte(`{{ block "body" . }}{{ nope }}{{ end }}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := lintParameters(t, &Config{TemplateLayout: layout}, nil, "a", `a{{ define "body" }}{{ end }}`, "b", `{{ define "body" }}{{ end }}`)
+	if report.Templates != 3 {
+		t.Errorf("expected 3 templates, got %d", report.Templates)
+	}
+	expected := []LintIssue{
+		{File: layout, Template: "body", Line: 1, Column: 23, Kind: LintFunction, Message: `function "nope" not defined`},
+		{File: "a", Template: "a", Kind: LintTemplate, Message: `template "a" is rendered within the layout "` + layout + `", but has content outside of define, which would not be rendered`},
+	}
+	issues := []LintIssue{}
+	for _, issue := range report.Issues {
+		issues = append(issues, *issue)
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("expected %+v, got %+v", expected, issues)
+	}
+}
+
+func TestLintReportSave(t *testing.T) {
+	report := lintParameters(t, &Config{}, nil, "a", `{{ nope }}`)
+	buf := &bytes.Buffer{}
+	err := report.Save(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"Templates": 1.0,
+		"Issues": []interface{}{map[string]interface{}{
+			"File":     "a",
+			"Template": "a",
+			"Line":     1.0,
+			"Column":   3.0,
+			"Kind":     LintFunction,
+			"Message":  `function "nope" not defined`,
+		}},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected %v, got %v", expected, decoded)
+	}
+	report = lintParameters(t, &Config{}, nil, "a", `a`)
+	buf.Reset()
+	err = report.Save(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{\n  \"Templates\": 1,\n  \"Issues\": []\n}\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
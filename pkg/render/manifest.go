@@ -0,0 +1,98 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestName is the name of the file in which RenderToDir records the
+// files it rendered to an output directory when pruning is enabled.
+const ManifestName = ".render-manifest"
+
+func readManifest(dir string) ([]string, error) {
+	f, err := os.Open(path.Join(dir, ManifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name := scanner.Text()
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+func writeManifest(dir string, names []string) error {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	buf := &bytes.Buffer{}
+	for _, name := range sorted {
+		buf.WriteString(name)
+		buf.WriteByte('\n')
+	}
+	_, err := writeFileIfChanged(path.Join(dir, ManifestName), buf.Bytes(), 0666)
+	return err
+}
+
+// staleOutputs returns the names recorded in dir's manifest that are not
+// among the given outputs.
+func staleOutputs(dir string, outputs []*output) ([]string, error) {
+	names := make([]string, len(outputs))
+	for i, output := range outputs {
+		names[i] = output.Name
+	}
+	return staleNames(dir, names)
+}
+
+func staleNames(dir string, names []string) ([]string, error) {
+	previous, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]bool{}
+	for _, name := range names {
+		current[path.Clean(name)] = true
+	}
+	stale := []string{}
+	for _, name := range previous {
+		name = path.Clean(name)
+		if current[name] || outsideDir(name) {
+			continue
+		}
+		stale = append(stale, name)
+	}
+	return stale, nil
+}
+
+// pruneOutputs removes the files recorded in dir's manifest that are not
+// among names, along with any directories left empty by their removal,
+// and then records names as the new manifest.
+func pruneOutputs(dir string, names []string) error {
+	stale, err := staleNames(dir, names)
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		err := os.Remove(path.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for parent := path.Dir(name); parent != "."; parent = path.Dir(parent) {
+			if os.Remove(filepath.Join(dir, parent)) != nil {
+				break
+			}
+		}
+	}
+	return writeManifest(dir, names)
+}
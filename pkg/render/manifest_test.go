@@ -0,0 +1,112 @@
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates the named files below dir, along with their parents.
+func writeFiles(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	names, err := readManifest(dir)
+	if err != nil || names != nil {
+		t.Fatalf("expected no manifest, got %q, %v", names, err)
+	}
+	err = writeManifest(dir, []string{"b", "a/c", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err = readManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a", "a/c", "b"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, got %q", expected, names)
+	}
+}
+
+func TestPruneOutputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	rendered := []string{"a", "sub/b", "sub/deep/c", "other/d"}
+	writeFiles(t, out, rendered...)
+	writeFiles(t, out, "user", "other/user")
+	writeFiles(t, dir, "outside")
+	err = pruneOutputs(out, rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pruneOutputs(out, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sub/b", "sub/deep/c", "sub/deep", "sub", "other/d"} {
+		if exists(filepath.Join(out, name)) {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+	for _, name := range []string{"a", "user", "other", "other/user"} {
+		if !exists(filepath.Join(out, name)) {
+			t.Errorf("expected %s to be kept", name)
+		}
+	}
+	names, err := readManifest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the manifest to list %q, got %q", expected, names)
+	}
+
+	outside := filepath.Join(dir, "outside")
+	err = ioutil.WriteFile(filepath.Join(out, ManifestName), []byte("a\n../outside\nx/../../outside\n"+outside+"\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := staleNames(out, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("expected entries outside the output directory to be ignored, got %q", stale)
+	}
+	err = pruneOutputs(out, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists(outside) {
+		t.Error("expected a file outside the output directory to be kept")
+	}
+	if exists(filepath.Join(out, "a")) {
+		t.Error("expected a to be removed")
+	}
+}
@@ -0,0 +1,132 @@
+package render
+
+import "fmt"
+
+// Strategies for combining variables from multiple sources
+const (
+	MergeOverwrite    = "overwrite"
+	MergeDeep         = "deep-merge"
+	MergeListsReplace = "replace"
+	MergeListsAppend  = "append"
+	MergeListsByKey   = "merge-by-key"
+)
+
+type mergeStrategy struct {
+	Maps    string
+	Lists   string
+	ListKey string
+}
+
+func (c *Config) varsMergeStrategy() mergeStrategy {
+	return mergeStrategy{
+		Maps:    MergeOverwrite,
+		Lists:   MergeListsReplace,
+		ListKey: "name",
+	}.override(c.VarsMerge, c.VarsMergeLists, c.VarsMergeListKey)
+}
+
+// override returns a copy of m with the non-empty arguments replacing its fields.
+func (m mergeStrategy) override(maps, lists, listKey string) mergeStrategy {
+	if maps != "" {
+		m.Maps = maps
+	}
+	if lists != "" {
+		m.Lists = lists
+	}
+	if listKey != "" {
+		m.ListKey = listKey
+	}
+	return m
+}
+
+func (m mergeStrategy) validate() error {
+	switch m.Maps {
+	case MergeOverwrite, MergeDeep:
+	default:
+		return fmt.Errorf("unknown merge strategy %q (expected %q or %q)", m.Maps, MergeOverwrite, MergeDeep)
+	}
+	switch m.Lists {
+	case MergeListsReplace, MergeListsAppend, MergeListsByKey:
+	default:
+		return fmt.Errorf("unknown list merge strategy %q (expected %q, %q or %q)", m.Lists, MergeListsReplace, MergeListsAppend, MergeListsByKey)
+	}
+	return nil
+}
+
+func (v Vars) mergeWith(other Vars, merge mergeStrategy) {
+	if merge.Maps != MergeDeep {
+		v.overwriteWith(other)
+		return
+	}
+	for key, value := range other {
+		v[key] = mergeValues(v[key], value, merge)
+	}
+}
+
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case Vars:
+		return m, true
+	case Files:
+		return m, true
+	}
+	return nil, false
+}
+
+// mergeValues deep-merges src into dst and returns the result. Maps are
+// merged recursively, lists according to the list strategy, and any other
+// value in src replaces dst.
+func mergeValues(dst, src interface{}, merge mergeStrategy) interface{} {
+	dstMap, dstIsMap := asMap(dst)
+	srcMap, srcIsMap := asMap(src)
+	if dstIsMap && srcIsMap {
+		for key, value := range srcMap {
+			dstMap[key] = mergeValues(dstMap[key], value, merge)
+		}
+		if _, ok := src.(Files); ok {
+			return Files(dstMap)
+		}
+		return dst
+	}
+	dstList, dstIsList := dst.([]interface{})
+	srcList, srcIsList := src.([]interface{})
+	if dstIsList && srcIsList {
+		switch merge.Lists {
+		case MergeListsAppend:
+			return append(append([]interface{}{}, dstList...), srcList...)
+		case MergeListsByKey:
+			return mergeListsByKey(dstList, srcList, merge)
+		}
+	}
+	return src
+}
+
+// mergeListsByKey deep-merges each map element of src into the map element of
+// dst that has the same value for the list key, and appends all other elements.
+func mergeListsByKey(dst, src []interface{}, merge mergeStrategy) []interface{} {
+	result := append([]interface{}{}, dst...)
+	for _, element := range src {
+		merged := false
+		if elementMap, ok := asMap(element); ok {
+			if key, ok := elementMap[merge.ListKey]; ok {
+				for i, existing := range result {
+					existingMap, ok := asMap(existing)
+					if !ok {
+						continue
+					}
+					if existingKey, ok := existingMap[merge.ListKey]; ok && fmt.Sprint(existingKey) == fmt.Sprint(key) {
+						result[i] = mergeValues(existing, element, merge)
+						merged = true
+						break
+					}
+				}
+			}
+		}
+		if !merged {
+			result = append(result, element)
+		}
+	}
+	return result
+}
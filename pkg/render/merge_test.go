@@ -0,0 +1,206 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeValues(t *testing.T) {
+	overwrite := mergeStrategy{Maps: MergeOverwrite, Lists: MergeListsReplace, ListKey: "name"}
+	replace := mergeStrategy{Maps: MergeDeep, Lists: MergeListsReplace, ListKey: "name"}
+	appendLists := mergeStrategy{Maps: MergeDeep, Lists: MergeListsAppend, ListKey: "name"}
+	byKey := mergeStrategy{Maps: MergeDeep, Lists: MergeListsByKey, ListKey: "name"}
+	tests := []struct {
+		name  string
+		dst   Vars
+		src   Vars
+		merge mergeStrategy
+		want  Vars
+	}{
+		{
+			name:  "overwrite replaces top-level values",
+			dst:   Vars{"a": map[string]interface{}{"b": 1, "c": 2}, "d": 3},
+			src:   Vars{"a": map[string]interface{}{"b": 4}},
+			merge: overwrite,
+			want:  Vars{"a": map[string]interface{}{"b": 4}, "d": 3},
+		},
+		{
+			name:  "deep merge merges maps recursively",
+			dst:   Vars{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}, "d": 2}},
+			src:   Vars{"a": map[string]interface{}{"b": map[string]interface{}{"e": 3}}},
+			merge: replace,
+			want:  Vars{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1, "e": 3}, "d": 2}},
+		},
+		{
+			name:  "deep merge replaces values of different types",
+			dst:   Vars{"a": map[string]interface{}{"b": 1}, "c": []interface{}{1}},
+			src:   Vars{"a": "x", "c": map[string]interface{}{"d": 2}},
+			merge: byKey,
+			want:  Vars{"a": "x", "c": map[string]interface{}{"d": 2}},
+		},
+		{
+			name:  "replace lists",
+			dst:   Vars{"a": []interface{}{1, 2}},
+			src:   Vars{"a": []interface{}{3}},
+			merge: replace,
+			want:  Vars{"a": []interface{}{3}},
+		},
+		{
+			name:  "append lists",
+			dst:   Vars{"a": []interface{}{1, 2}},
+			src:   Vars{"a": []interface{}{2, 3}},
+			merge: appendLists,
+			want:  Vars{"a": []interface{}{1, 2, 2, 3}},
+		},
+		{
+			name:  "merge lists by key",
+			dst:   Vars{"a": []interface{}{map[string]interface{}{"name": "x", "v": 1}, map[string]interface{}{"name": "y", "v": 2}}},
+			src:   Vars{"a": []interface{}{map[string]interface{}{"name": "y", "w": 3}, map[string]interface{}{"name": "z"}}},
+			merge: byKey,
+			want: Vars{"a": []interface{}{
+				map[string]interface{}{"name": "x", "v": 1},
+				map[string]interface{}{"name": "y", "v": 2, "w": 3},
+				map[string]interface{}{"name": "z"},
+			}},
+		},
+		{
+			name:  "Files are merged as maps and stay Files",
+			dst:   Vars{"f": map[string]interface{}{"a.txt": "a"}},
+			src:   Vars{"f": Files{"b.txt": "b"}},
+			merge: replace,
+			want:  Vars{"f": Files{"a.txt": "a", "b.txt": "b"}},
+		},
+		{
+			name:  "Files replace scalars",
+			dst:   Vars{"f": "x"},
+			src:   Vars{"f": Files{"b.txt": "b"}},
+			merge: replace,
+			want:  Vars{"f": Files{"b.txt": "b"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.dst.mergeWith(test.src, test.merge)
+			if !reflect.DeepEqual(test.dst, test.want) {
+				t.Errorf("expected %#v, got %#v", test.want, test.dst)
+			}
+		})
+	}
+}
+
+func TestMergeListsByKey(t *testing.T) {
+	merge := mergeStrategy{Maps: MergeDeep, Lists: MergeListsByKey, ListKey: "id"}
+	tests := []struct {
+		name string
+		dst  []interface{}
+		src  []interface{}
+		want []interface{}
+	}{
+		{
+			name: "matching keys are merged in place",
+			dst:  []interface{}{map[string]interface{}{"id": 1, "a": 1}, map[string]interface{}{"id": 2}},
+			src:  []interface{}{map[string]interface{}{"id": 1, "b": 2}},
+			want: []interface{}{map[string]interface{}{"id": 1, "a": 1, "b": 2}, map[string]interface{}{"id": 2}},
+		},
+		{
+			name: "keys are compared as text",
+			dst:  []interface{}{map[string]interface{}{"id": 1}},
+			src:  []interface{}{map[string]interface{}{"id": "1", "a": true}},
+			want: []interface{}{map[string]interface{}{"id": "1", "a": true}},
+		},
+		{
+			name: "elements without the key are appended",
+			dst:  []interface{}{map[string]interface{}{"name": "x"}},
+			src:  []interface{}{map[string]interface{}{"name": "x"}},
+			want: []interface{}{map[string]interface{}{"name": "x"}, map[string]interface{}{"name": "x"}},
+		},
+		{
+			name: "non-map elements are appended",
+			dst:  []interface{}{"a", map[string]interface{}{"id": 1}},
+			src:  []interface{}{"a", 2, []interface{}{3}},
+			want: []interface{}{"a", map[string]interface{}{"id": 1}, "a", 2, []interface{}{3}},
+		},
+		{
+			name: "non-map elements of dst are skipped when matching",
+			dst:  []interface{}{"a", map[string]interface{}{"id": 1}},
+			src:  []interface{}{map[string]interface{}{"id": 1, "b": 2}},
+			want: []interface{}{"a", map[string]interface{}{"id": 1, "b": 2}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst := append([]interface{}{}, test.dst...)
+			got := mergeListsByKey(test.dst, test.src, merge)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %#v, got %#v", test.want, got)
+			}
+			if len(test.dst) != len(dst) {
+				t.Errorf("expected dst to keep its length, got %#v", test.dst)
+			}
+		})
+	}
+}
+
+func TestVarsSourceLoadMerge(t *testing.T) {
+	defaults := mergeStrategy{Maps: MergeOverwrite, Lists: MergeListsReplace, ListKey: "name"}
+	parameter := func(key, value string) *VarsSourceParameter {
+		return &VarsSourceParameter{Key: key, Value: value, Format: "json"}
+	}
+	tests := []struct {
+		name   string
+		vars   Vars
+		source *VarsSource
+		want   Vars
+		loaded Vars
+	}{
+		{
+			name:   "default strategy",
+			vars:   Vars{"a": map[string]interface{}{"b": 1.0}},
+			source: &VarsSource{FromParameter: parameter("a", `{"c": 2}`)},
+			want:   Vars{"a": map[string]interface{}{"c": 2.0}},
+			loaded: Vars{"a": map[string]interface{}{"c": 2.0}},
+		},
+		{
+			name:   "per-source deep merge",
+			vars:   Vars{"a": map[string]interface{}{"b": 1.0}},
+			source: &VarsSource{Merge: MergeDeep, FromParameter: parameter("a", `{"c": 2}`)},
+			want:   Vars{"a": map[string]interface{}{"b": 1.0, "c": 2.0}},
+			loaded: Vars{"a": map[string]interface{}{"c": 2.0}},
+		},
+		{
+			name:   "per-source list strategy and key",
+			vars:   Vars{"l": []interface{}{map[string]interface{}{"id": "x", "a": 1.0}}},
+			source: &VarsSource{Merge: MergeDeep, MergeLists: MergeListsByKey, MergeListKey: "id", FromParameter: parameter("l", `[{"id": "x", "b": 2}]`)},
+			want:   Vars{"l": []interface{}{map[string]interface{}{"id": "x", "a": 1.0, "b": 2.0}}},
+			loaded: Vars{"l": []interface{}{map[string]interface{}{"id": "x", "b": 2.0}}},
+		},
+		{
+			name:   "keyed source into an existing map",
+			vars:   Vars{"k": map[string]interface{}{"a": 1.0, "b": 2.0}},
+			source: &VarsSource{Key: "k", FromParameter: parameter("a", `3`)},
+			want:   Vars{"k": map[string]interface{}{"a": 3.0, "b": 2.0}},
+			loaded: Vars{"k": map[string]interface{}{"a": 3.0}},
+		},
+		{
+			name:   "keyed source replacing a scalar",
+			vars:   Vars{"k": "x"},
+			source: &VarsSource{Key: "k", FromParameter: parameter("a.b", `true`)},
+			want:   Vars{"k": map[string]interface{}{"a": map[string]interface{}{"b": true}}},
+			loaded: Vars{"k": map[string]interface{}{"a": map[string]interface{}{"b": true}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loaded, err := test.source.load(test.vars, defaults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.vars, test.want) {
+				t.Errorf("expected vars %#v, got %#v", test.want, test.vars)
+			}
+			if !reflect.DeepEqual(loaded, test.loaded) {
+				t.Errorf("expected loaded %#v, got %#v", test.loaded, loaded)
+			}
+		})
+	}
+}
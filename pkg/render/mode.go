@@ -0,0 +1,95 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gobwas/glob"
+)
+
+// TemplateOutMode sets the mode of output files whose path below the output
+// directory matches Glob (e.g. "secrets/**") to Mode, in octal (e.g. "0600").
+type TemplateOutMode struct {
+	Glob string
+	Mode string
+}
+
+type outMode struct {
+	glob glob.Glob
+	mode os.FileMode
+}
+
+// parseMode parses file permissions given in octal, e.g. "0755".
+func parseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+func compileOutModes(modes []*TemplateOutMode) ([]*outMode, error) {
+	result := make([]*outMode, len(modes))
+	for i, m := range modes {
+		g, err := glob.Compile(m.Glob, '/')
+		if err != nil {
+			return nil, err
+		}
+		mode, err := parseMode(m.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.Glob, err)
+		}
+		result[i] = &outMode{glob: g, mode: mode}
+	}
+	return result, nil
+}
+
+// outputMode returns the mode of the named template's output file: the mode
+// given in its front matter, or that of the last matching output mode rule,
+// or the mode of the file it was loaded from. It is zero if none apply.
+func (t *Templates) outputMode(name, outputName string) (os.FileMode, error) {
+	mode, err := t.FrontMatter[name].mode()
+	if err != nil || mode != 0 {
+		return mode, err
+	}
+	for i := len(t.OutModes) - 1; i >= 0; i-- {
+		if t.OutModes[i].glob.Match(outputName) {
+			return t.OutModes[i].mode, nil
+		}
+	}
+	return t.Modes[name], nil
+}
+
+// mkdirAll is like os.MkdirAll, but if mode is not zero, sets the mode of
+// each directory it creates to exactly mode.
+func mkdirAll(dir string, mode os.FileMode) error {
+	if mode == 0 {
+		return os.MkdirAll(dir, 0777|os.ModeDir)
+	}
+	info, err := os.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s: not a directory", dir)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		err = mkdirAll(parent, mode)
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Mkdir(dir, mode|os.ModeDir)
+	if os.IsExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chmod(dir, mode|os.ModeDir)
+}
@@ -0,0 +1,75 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var errFileUnavailable = errors.New("file: only available in output templates, and not within include or tpl")
+
+// fileStart records that the output of a file started by the file function
+// begins at the given offset in the template's output.
+type fileStart struct {
+	Name   string
+	Offset int
+}
+
+// countingWriter counts the bytes written to it, so that the file function
+// knows where in the output it is called.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += n
+	return n, err
+}
+
+// file marks the start of a separate output file in a template's output:
+// everything rendered after it, up to the next call to file or the end of the
+// template, is written to the given path below the output directory.
+func (e *execution) file(name string) (string, error) {
+	if e.out == nil || e.depth > 0 {
+		return "", errFileUnavailable
+	}
+	cleanName := path.Clean(name)
+	if name == "" || strings.IndexByte(name, 0) >= 0 {
+		return "", fmt.Errorf("file: invalid path %q", name)
+	}
+	if outsideDir(cleanName) {
+		return "", fmt.Errorf("file: path %q is outside the output directory", name)
+	}
+	e.files = append(e.files, fileStart{Name: cleanName, Offset: e.out.n})
+	return "", nil
+}
+
+// splitFiles splits an output at the files started by the file function. The
+// content before the first file is kept as the original output unless it is
+// only whitespace.
+func splitFiles(templateName string, o *output) ([]*output, error) {
+	if len(o.files) == 0 {
+		return []*output{o}, nil
+	}
+	outputs := []*output{}
+	if first := o.Content[:o.files[0].Offset]; len(strings.TrimSpace(string(first))) > 0 {
+		outputs = append(outputs, &output{Name: o.Name, Content: first})
+	}
+	seen := map[string]bool{}
+	for i, start := range o.files {
+		if seen[start.Name] {
+			return nil, fmt.Errorf("template %q: file %q is rendered more than once", templateName, start.Name)
+		}
+		seen[start.Name] = true
+		end := len(o.Content)
+		if i+1 < len(o.files) {
+			end = o.files[i+1].Offset
+		}
+		outputs = append(outputs, &output{Name: start.Name, Content: o.Content[start.Offset:end]})
+	}
+	return outputs, nil
+}
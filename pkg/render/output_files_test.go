@@ -0,0 +1,87 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		vars    map[string]interface{}
+		outputs map[string]string
+		err     string
+	}{
+		{
+			name:    "no files",
+			text:    "a",
+			outputs: map[string]string{"t": "a"},
+		},
+		{
+			name:    "files",
+			text:    `{{ range .xs }}{{ file (printf "%s.txt" .) }}{{ . }}{{ end }}`,
+			vars:    map[string]interface{}{"xs": []interface{}{"a", "b"}},
+			outputs: map[string]string{"a.txt": "a", "b.txt": "b"},
+		},
+		{
+			name:    "content before the first file",
+			text:    `head{{ file "a" }}a`,
+			outputs: map[string]string{"t": "head", "a": "a"},
+		},
+		{
+			name:    "whitespace before the first file",
+			text:    "\n {{ file \"a\" }}a",
+			outputs: map[string]string{"a": "a"},
+		},
+		{
+			name:    "cleaned path",
+			text:    `{{ file "x/../a" }}a`,
+			outputs: map[string]string{"a": "a"},
+		},
+		{
+			name:    "marker in variable",
+			text:    `{{ .x }}`,
+			vars:    map[string]interface{}{"x": "\x00render:file\x00../../escaped.txt\x00pwned"},
+			outputs: map[string]string{"t": "\x00render:file\x00../../escaped.txt\x00pwned"},
+		},
+		{
+			name: "duplicate file",
+			text: `{{ file "a" }}{{ file "a" }}`,
+			err:  `file "a" is rendered more than once`,
+		},
+		{
+			name: "parent directory",
+			text: `{{ file "../a" }}`,
+			err:  "outside the output directory",
+		},
+		{
+			name: "absolute path",
+			text: `{{ file "/a" }}`,
+			err:  "outside the output directory",
+		},
+		{
+			name: "within include",
+			text: `{{ define "f" }}{{ file "a" }}{{ end }}{{ include "f" . }}`,
+			err:  errFileUnavailable.Error(),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			templates := parameterTemplates(t, &Config{}, "t", test.text)
+			templates.Vars = test.vars
+			if test.err != "" {
+				_, err := templates.outputs()
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			contents := renderedOutputs(t, templates)
+			if !reflect.DeepEqual(contents, test.outputs) {
+				t.Errorf("expected %q, got %q", test.outputs, contents)
+			}
+		})
+	}
+}
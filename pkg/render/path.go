@@ -0,0 +1,153 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// maxListIndex bounds the list indices in variable paths, since setPath fills
+// lists up to the index given.
+const maxListIndex = 1<<16 - 1
+
+// joinKey appends a map key to a variable path, e.g. "a" + "b" = "a.b".
+// Keys that are not identifiers are quoted: "a" + "b.c" = `a["b.c"]`.
+func joinKey(path, key string) string {
+	if !identifierPattern.MatchString(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// joinIndex appends a list index to a variable path, e.g. "a" + 0 = "a[0]".
+func joinIndex(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// parsePath splits a variable path such as `a.b[0]["c.d"]` into its
+// segments, which are either map keys (strings) or list indices (ints).
+func parsePath(path string) ([]interface{}, error) {
+	segments := []interface{}{}
+	rest := path
+	for rest != "" {
+		switch rest[0] {
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if strings.HasPrefix(rest, `["`) {
+				quoted, err := strconv.QuotedPrefix(rest[1:])
+				if err != nil || !strings.HasPrefix(rest[1+len(quoted):], "]") {
+					return nil, fmt.Errorf("path %q: unterminated quoted key", path)
+				}
+				key, _ := strconv.Unquote(quoted)
+				segments = append(segments, key)
+				rest = rest[1+len(quoted)+1:]
+			} else if end < 0 {
+				return nil, fmt.Errorf("path %q: missing ]", path)
+			} else {
+				index, err := strconv.Atoi(rest[1:end])
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("path %q: invalid list index %q", path, rest[1:end])
+				}
+				if index > maxListIndex {
+					return nil, fmt.Errorf("path %q: list index %d is greater than %d", path, index, maxListIndex)
+				}
+				segments = append(segments, index)
+				rest = rest[end+1:]
+			}
+		case '.':
+			if len(segments) == 0 || rest == "." {
+				return nil, fmt.Errorf("path %q: empty key", path)
+			}
+			rest = rest[1:]
+			if rest[0] == '.' || rest[0] == '[' {
+				return nil, fmt.Errorf("path %q: empty key", path)
+			}
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		}
+	}
+	if len(segments) == 0 {
+		return nil, errors.New("empty path")
+	}
+	return segments, nil
+}
+
+// setPath sets the value at the given path below vars, creating maps and
+// lists along the path as needed. The value is merged into any existing value
+// at the path according to the merge strategy.
+func setPath(vars Vars, path []interface{}, value interface{}, merge mergeStrategy) {
+	var container interface{} = map[string]interface{}(vars)
+	set := func(v interface{}) {}
+	for i, segment := range path {
+		last := i == len(path)-1
+		var current interface{}
+		switch segment := segment.(type) {
+		case string:
+			m, ok := asMap(container)
+			if !ok {
+				m = map[string]interface{}{}
+				set(m)
+			}
+			current = m[segment]
+			set = func(v interface{}) { m[segment] = v }
+		case int:
+			list, ok := container.([]interface{})
+			if !ok {
+				list = []interface{}{}
+			}
+			for len(list) <= segment {
+				list = append(list, nil)
+			}
+			set(list)
+			current = list[segment]
+			set = func(v interface{}) { list[segment] = v }
+		}
+		if last {
+			if merge.Maps == MergeDeep {
+				set(mergeValues(current, value, merge))
+			} else {
+				set(value)
+			}
+			return
+		}
+		container = current
+	}
+}
+
+// getPath returns the value at the given path below vars, and whether there
+// is such a value.
+func getPath(vars Vars, path []interface{}) (interface{}, bool) {
+	var current interface{} = map[string]interface{}(vars)
+	for _, segment := range path {
+		switch segment := segment.(type) {
+		case string:
+			m, ok := asMap(current)
+			if !ok {
+				return nil, false
+			}
+			current, ok = m[segment]
+			if !ok {
+				return nil, false
+			}
+		case int:
+			list, ok := current.([]interface{})
+			if !ok || segment >= len(list) {
+				return nil, false
+			}
+			current = list[segment]
+		}
+	}
+	return current, true
+}
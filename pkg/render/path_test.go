@@ -0,0 +1,180 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		segments []interface{}
+		err      string
+	}{
+		{path: "a", segments: []interface{}{"a"}},
+		{path: "a.b-c.d_e", segments: []interface{}{"a", "b-c", "d_e"}},
+		{path: "a[0][12]", segments: []interface{}{"a", 0, 12}},
+		{path: "a[1].b", segments: []interface{}{"a", 1, "b"}},
+		{path: `a["b.c"]`, segments: []interface{}{"a", "b.c"}},
+		{path: `a["b\"]"].c`, segments: []interface{}{"a", `b"]`, "c"}},
+		{path: `["a b"][0]`, segments: []interface{}{"a b", 0}},
+		{path: "a[65535]", segments: []interface{}{"a", 65535}},
+		{path: "", err: "empty path"},
+		{path: ".a", err: "empty key"},
+		{path: "a.", err: "empty key"},
+		{path: "a..b", err: "empty key"},
+		{path: "a.[0]", err: "empty key"},
+		{path: "a[0", err: "missing ]"},
+		{path: "a[x]", err: "invalid list index"},
+		{path: "a[-1]", err: "invalid list index"},
+		{path: "a[]", err: "invalid list index"},
+		{path: "a[999999999999999999999]", err: "invalid list index"},
+		{path: "a[999999999999]", err: "greater than 65535"},
+		{path: `a["b`, err: "unterminated quoted key"},
+		{path: `a["b"`, err: "unterminated quoted key"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			segments, err := parsePath(test.path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(segments, test.segments) {
+				t.Errorf("expected %#v, got %#v", test.segments, segments)
+			}
+		})
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	overwrite := mergeStrategy{Maps: MergeOverwrite, Lists: MergeListsReplace}
+	deep := mergeStrategy{Maps: MergeDeep, Lists: MergeListsAppend}
+	tests := []struct {
+		name  string
+		vars  Vars
+		path  string
+		value interface{}
+		merge mergeStrategy
+		want  Vars
+	}{
+		{
+			name:  "new key",
+			vars:  Vars{},
+			path:  "a.b",
+			value: "x",
+			merge: overwrite,
+			want:  Vars{"a": map[string]interface{}{"b": "x"}},
+		},
+		{
+			name:  "keeps siblings",
+			vars:  Vars{"a": map[string]interface{}{"b": "x", "c": "y"}},
+			path:  "a.b",
+			value: "z",
+			merge: overwrite,
+			want:  Vars{"a": map[string]interface{}{"b": "z", "c": "y"}},
+		},
+		{
+			name:  "replaces a scalar on the path",
+			vars:  Vars{"a": "x"},
+			path:  "a.b",
+			value: "y",
+			merge: overwrite,
+			want:  Vars{"a": map[string]interface{}{"b": "y"}},
+		},
+		{
+			name:  "list index",
+			vars:  Vars{"a": []interface{}{"x", "y"}},
+			path:  "a[1]",
+			value: "z",
+			merge: overwrite,
+			want:  Vars{"a": []interface{}{"x", "z"}},
+		},
+		{
+			name:  "list index past the end",
+			vars:  Vars{},
+			path:  "a[2].b",
+			value: "x",
+			merge: overwrite,
+			want:  Vars{"a": []interface{}{nil, nil, map[string]interface{}{"b": "x"}}},
+		},
+		{
+			name:  "quoted key",
+			vars:  Vars{},
+			path:  `a["b.c"]`,
+			value: "x",
+			merge: overwrite,
+			want:  Vars{"a": map[string]interface{}{"b.c": "x"}},
+		},
+		{
+			name:  "overwrite",
+			vars:  Vars{"a": map[string]interface{}{"b": map[string]interface{}{"c": "x"}}},
+			path:  "a.b",
+			value: map[string]interface{}{"d": "y"},
+			merge: overwrite,
+			want:  Vars{"a": map[string]interface{}{"b": map[string]interface{}{"d": "y"}}},
+		},
+		{
+			name:  "deep merge",
+			vars:  Vars{"a": map[string]interface{}{"b": map[string]interface{}{"c": "x"}, "l": []interface{}{1}}},
+			path:  "a",
+			value: map[string]interface{}{"b": map[string]interface{}{"d": "y"}, "l": []interface{}{2}},
+			merge: deep,
+			want:  Vars{"a": map[string]interface{}{"b": map[string]interface{}{"c": "x", "d": "y"}, "l": []interface{}{1, 2}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, err := parsePath(test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setPath(test.vars, path, test.value, test.merge)
+			if !reflect.DeepEqual(test.vars, test.want) {
+				t.Errorf("expected %#v, got %#v", test.want, test.vars)
+			}
+		})
+	}
+}
+
+func TestVarsSourceParameterFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		value  string
+		want   interface{}
+		err    bool
+	}{
+		{format: "", value: "5432", want: "5432"},
+		{format: "yaml", value: "5432", want: 5432},
+		{format: "yaml", value: "[a, b]", want: []interface{}{"a", "b"}},
+		{format: "yaml", value: "{a: 1}", want: map[string]interface{}{"a": 1}},
+		{format: "json", value: `{"a": 1}`, want: map[string]interface{}{"a": 1.0}},
+		{format: "json", value: "{", err: true},
+		{format: "xml", value: "x", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.format+" "+test.value, func(t *testing.T) {
+			vars := Vars{}
+			err := (&VarsSourceParameter{Key: "a.b", Value: test.value, Format: test.format}).Load(vars)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Vars{"a": map[string]interface{}{"b": test.want}}
+			if !reflect.DeepEqual(vars, want) {
+				t.Errorf("expected %#v, got %#v", want, vars)
+			}
+		})
+	}
+}
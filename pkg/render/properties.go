@@ -0,0 +1,224 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// propertiesLogicalLines joins lines ending in an odd number of backslashes
+// with the following line, dropping the continuation line's leading whitespace.
+// It returns each logical line with the number of the line it starts on.
+func propertiesLogicalLines(data []byte) ([]string, []int) {
+	natural := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	lines := []string{}
+	numbers := []int{}
+	for n := 0; n < len(natural); n++ {
+		start := n + 1
+		line := strings.TrimLeft(natural[n], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for {
+			trailing := len(line) - len(strings.TrimRight(line, `\`))
+			if trailing%2 == 0 || n+1 >= len(natural) {
+				if trailing%2 == 1 {
+					line = line[:len(line)-1]
+				}
+				break
+			}
+			n++
+			line = line[:len(line)-1] + strings.TrimLeft(natural[n], " \t\f")
+		}
+		lines = append(lines, line)
+		numbers = append(numbers, start)
+	}
+	return lines, numbers
+}
+
+func unescapeProperties(s string) (string, error) {
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			buf.WriteByte('\t')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape %q", s[i-1:])
+			}
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape %q", s[i-1:i+5])
+			}
+			i += 4
+			r := rune(code)
+			if utf16.IsSurrogate(r) && strings.HasPrefix(s[i+1:], `\u`) && i+7 <= len(s) {
+				if low, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if pair := utf16.DecodeRune(r, rune(low)); pair != unicode.ReplacementChar {
+						r = pair
+						i += 6
+					}
+				}
+			}
+			buf.WriteRune(r)
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String(), nil
+}
+
+// parseProperties parses the contents of a Java .properties file into a flat
+// map from keys to string values.
+func parseProperties(data []byte) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	lines, numbers := propertiesLogicalLines(data)
+	for i, line := range lines {
+		end := len(line)
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+				continue
+			}
+			if strings.IndexByte("=: \t\f", line[j]) >= 0 {
+				end = j
+				break
+			}
+		}
+		rawValue := strings.TrimLeft(line[end:], " \t\f")
+		if rawValue != "" && (rawValue[0] == '=' || rawValue[0] == ':') {
+			rawValue = strings.TrimLeft(rawValue[1:], " \t\f")
+		}
+		key, err := unescapeProperties(line[:end])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+		}
+		value, err := unescapeProperties(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", numbers[i], err)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// expandDottedKeys turns a flat map with keys such as "a.b.c" into nested maps.
+func expandDottedKeys(flat map[string]interface{}) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	nested := map[string]interface{}{}
+	for _, key := range keys {
+		parts := strings.Split(key, ".")
+		m := nested
+		for i, part := range parts[:len(parts)-1] {
+			child, ok := m[part]
+			if !ok {
+				child = map[string]interface{}{}
+				m[part] = child
+			}
+			childMap, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %q conflicts with key %q", key, strings.Join(parts[:i+1], "."))
+			}
+			m = childMap
+		}
+		last := parts[len(parts)-1]
+		if _, ok := m[last]; ok {
+			return nil, fmt.Errorf("key %q conflicts with keys below it", key)
+		}
+		m[last] = flat[key]
+	}
+	return nested, nil
+}
+
+// flattenDottedKeys is the inverse of expandDottedKeys.
+func flattenDottedKeys(prefix string, value interface{}, flat map[string]interface{}) {
+	m, ok := asMap(value)
+	if !ok {
+		flat[prefix] = value
+		return
+	}
+	for key, v := range m {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flattenDottedKeys(key, v, flat)
+	}
+}
+
+func escapeProperties(s string, isKey bool) string {
+	buf := &bytes.Buffer{}
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			buf.WriteString(`\ `)
+		case strings.ContainsRune("=:#!", r) && (isKey || i == 0):
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			if r > 0xffff {
+				high, low := utf16.EncodeRune(r)
+				fmt.Fprintf(buf, `\u%04x\u%04x`, high, low)
+			} else {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+// formatProperties formats a map as a .properties file. Nested maps are
+// flattened into dotted keys.
+func formatProperties(vars map[string]interface{}) (string, error) {
+	flat := map[string]interface{}{}
+	flattenDottedKeys("", vars, flat)
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	buf := &bytes.Buffer{}
+	for _, key := range keys {
+		value := flat[key]
+		if _, ok := value.([]interface{}); ok {
+			return "", fmt.Errorf("value of %q is a list, not a scalar", key)
+		}
+		s := ""
+		if value != nil {
+			s = fmt.Sprint(value)
+		}
+		fmt.Fprintf(buf, "%s=%s\n", escapeProperties(key, true), escapeProperties(s, false))
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,117 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProperties(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		vars map[string]interface{}
+		err  string
+	}{
+		{"separators", "a=1\nb:2\nc 3\nd = 4\ne\t:\t5\n", map[string]interface{}{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}, ""},
+		{"comments", "# x\n! y\n  # z\na=1\n", map[string]interface{}{"a": "1"}, ""},
+		{"key without value", "a\nb=\n", map[string]interface{}{"a": "", "b": ""}, ""},
+		{"trailing whitespace is kept", "a=1 \n", map[string]interface{}{"a": "1 "}, ""},
+		{"continuation lines", "a=one, \\\n    two, \\\n    three\nb=c\n", map[string]interface{}{"a": "one, two, three", "b": "c"}, ""},
+		{"escaped backslash at end of line", "a=x\\\\\nb=y\n", map[string]interface{}{"a": `x\`, "b": "y"}, ""},
+		{"continuation at end of file", "a=x\\", map[string]interface{}{"a": "x"}, ""},
+		{"escapes in keys", "a\\ b\\=c\\:d=1\n", map[string]interface{}{"a b=c:d": "1"}, ""},
+		{"escapes in values", `a=\t\n\r\f\u00e4\\\q`, map[string]interface{}{"a": "\t\n\r\f\u00e4\\q"}, ""},
+		{"surrogate pair", `a=\ud83d\ude00`, map[string]interface{}{"a": "\U0001f600"}, ""},
+		{"CRLF", "a=1\r\nb=2\r\n", map[string]interface{}{"a": "1", "b": "2"}, ""},
+		{"later keys override", "a=1\na=2\n", map[string]interface{}{"a": "2"}, ""},
+		{"malformed unicode escape", "a=1\nb=\\u12\n", nil, `line 2: malformed \u escape`},
+		{"invalid unicode escape", "a=\\uzzzz\n", nil, `line 1: malformed \u escape`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars, err := parseProperties([]byte(test.data))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(vars, test.vars) {
+				t.Errorf("expected %q, got %q", test.vars, vars)
+			}
+		})
+	}
+}
+
+func TestExpandDottedKeys(t *testing.T) {
+	tests := []struct {
+		flat   map[string]interface{}
+		nested map[string]interface{}
+		err    string
+	}{
+		{
+			flat:   map[string]interface{}{"a.b": "1", "a.c.d": "2", "e": "3"},
+			nested: map[string]interface{}{"a": map[string]interface{}{"b": "1", "c": map[string]interface{}{"d": "2"}}, "e": "3"},
+		},
+		{flat: map[string]interface{}{"a": "1", "a.b": "2"}, err: `key "a.b" conflicts with key "a"`},
+	}
+	for _, test := range tests {
+		nested, err := expandDottedKeys(test.flat)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v: expected error %q, got %v", test.flat, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.flat, err)
+			continue
+		}
+		if !reflect.DeepEqual(nested, test.nested) {
+			t.Errorf("expected %v, got %v", test.nested, nested)
+		}
+	}
+}
+
+func TestFormatProperties(t *testing.T) {
+	vars := map[string]interface{}{
+		"db":    map[string]interface{}{"host": "localhost", "port": 5432},
+		"a b":   " leading space",
+		"x=y":   "#not a comment",
+		"emoji": "\U0001f600 ä",
+		"lines": "one\ntwo",
+	}
+	formatted, err := formatProperties(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `a\ b=\ leading space
+db.host=localhost
+db.port=5432
+emoji=\ud83d\ude00 \u00e4
+lines=one\ntwo
+x\=y=\#not a comment
+`
+	if formatted != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, formatted)
+	}
+	parsed, err := parseProperties([]byte(formatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err = expandDottedKeys(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars["db"].(map[string]interface{})["port"] = "5432"
+	if !reflect.DeepEqual(parsed, vars) {
+		t.Errorf("expected %q, got %q", vars, parsed)
+	}
+	if _, err := formatProperties(map[string]interface{}{"a": []interface{}{}}); err == nil {
+		t.Error("expected an error for a list value")
+	}
+}
@@ -0,0 +1,313 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Origin describes the variable source that set a value.
+type Origin struct {
+	Source string
+	Name   string `json:",omitempty"`
+	Line   int    `json:",omitempty"`
+}
+
+func (o Origin) String() string {
+	s := o.Source
+	if o.Name != "" {
+		s += " " + o.Name
+	}
+	if o.Line > 0 {
+		s += fmt.Sprintf(":%d", o.Line)
+	}
+	return s
+}
+
+// Provenance maps the path of each leaf variable to the origins of the
+// values assigned to it, in order. The last origin set the current value.
+type Provenance map[string][]Origin
+
+// Save writes one line per leaf variable with its path, value, origin and
+// the origins it overrode.
+func (p Provenance) Save(w io.Writer, vars Vars) error {
+	leaves := map[string]interface{}{}
+	leafValues(map[string]interface{}(vars), "", leaves)
+	paths := make([]string, 0, len(leaves))
+	for path := range leaves {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, path := range paths {
+		value, err := json.Marshal(leaves[path])
+		if err != nil {
+			value = []byte(fmt.Sprint(leaves[path]))
+		}
+		origins := p[path]
+		origin := "unknown"
+		if len(origins) > 0 {
+			origin = origins[len(origins)-1].String()
+		}
+		overridden := ""
+		if len(origins) > 1 {
+			previous := make([]string, len(origins)-1)
+			for i, o := range origins[:len(origins)-1] {
+				previous[i] = o.String()
+			}
+			overridden = "(overrides " + strings.Join(previous, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", path, value, origin, overridden)
+	}
+	return tw.Flush()
+}
+
+// leafValues collects the non-container values (and empty containers)
+// below value, keyed by their path.
+func leafValues(value interface{}, path string, leaves map[string]interface{}) {
+	if m, ok := asMap(value); ok && len(m) > 0 {
+		for key, v := range m {
+			leafValues(v, joinKey(path, key), leaves)
+		}
+		return
+	}
+	if s, ok := value.([]interface{}); ok && len(s) > 0 {
+		for i, v := range s {
+			leafValues(v, joinIndex(path, i), leaves)
+		}
+		return
+	}
+	leaves[path] = value
+}
+
+// FromConfigWithProvenance loads variables like FromConfig and additionally
+// records which variable source last set each value.
+func (v Vars) FromConfigWithProvenance(config *Config) (Provenance, error) {
+	provenance := Provenance{}
+	merge := config.varsMergeStrategy()
+	for _, varsSource := range config.VarsSources {
+		before := map[string]interface{}{}
+		leafValues(map[string]interface{}(v), "", before)
+		loaded, err := varsSource.load(v, merge)
+		if err != nil {
+			return nil, err
+		}
+		contributed := map[string]interface{}{}
+		leafValues(map[string]interface{}(loaded), "", contributed)
+		after := map[string]interface{}{}
+		leafValues(map[string]interface{}(v), "", after)
+		origin := varsSource.origin()
+		lines := varsSource.lines()
+		keyPrefix := ""
+		if varsSource.Key != "" {
+			keyPrefix = joinKey("", varsSource.Key)
+		}
+		for path, value := range after {
+			previous, existed := before[path]
+			contributedValue, isContributed := contributed[path]
+			if existed && reflect.DeepEqual(previous, value) && !(isContributed && reflect.DeepEqual(contributedValue, value)) {
+				continue
+			}
+			o := origin
+			o.Line = lineOf(lines, strings.TrimPrefix(strings.TrimPrefix(path, keyPrefix), "."))
+			provenance[path] = append(provenance[path], o)
+		}
+	}
+	before := map[string]interface{}{}
+	leafValues(map[string]interface{}(v), "", before)
+	err := v.applySchema(config)
+	after := map[string]interface{}{}
+	leafValues(map[string]interface{}(v), "", after)
+	for path := range after {
+		if _, existed := before[path]; !existed {
+			provenance[path] = append(provenance[path], Origin{Source: "schema default", Name: config.VarsSchema})
+		}
+	}
+	return provenance, err
+}
+
+// lineOf returns the line of path, or of its closest ancestor with a known line.
+func lineOf(lines map[string]int, path string) int {
+	for path != "" {
+		if line, ok := lines[path]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return 0
+}
+
+func (v *VarsSource) origin() Origin {
+	switch {
+	case v.FromEnv != nil:
+		return Origin{Source: "env", Name: v.FromEnv.Glob}
+	case v.FromFile != nil:
+		return Origin{Source: "file", Name: v.FromFile.Path}
+	case v.FromFileDocuments != nil:
+		return Origin{Source: "file-documents", Name: v.FromFileDocuments.Path}
+	case v.FromFileSlurp != nil:
+		return Origin{Source: "file-slurp", Name: v.FromFileSlurp.Path}
+	case v.FromFilesSlurp != nil:
+		return Origin{Source: "files-slurp", Name: v.FromFilesSlurp.Glob}
+	case v.FromParameter != nil:
+		return Origin{Source: "parameter", Name: v.FromParameter.Key}
+	case v.FromStdin != nil:
+		return Origin{Source: "stdin"}
+	}
+	return Origin{}
+}
+
+// lines maps the variable paths defined by the source to the line they are
+// defined on, where that is known.
+func (v *VarsSource) lines() map[string]int {
+	if v.FromFile == nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(v.FromFile.Path)
+	if err != nil {
+		return nil
+	}
+	format := v.FromFile.format()
+	if format == "" {
+		format = guessFormat(data)
+	}
+	switch format {
+	case FormatJSON:
+		return jsonLines(data)
+	case FormatYAML:
+		return yamlLines(data)
+	}
+	return nil
+}
+
+func jsonLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	lineAt := func() int {
+		return 1 + bytes.Count(data[:dec.InputOffset()], []byte{'\n'})
+	}
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			if _, ok := lines[path]; !ok {
+				lines[path] = lineAt()
+			}
+		}
+		switch token {
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				keyPath := joinKey(path, fmt.Sprint(key))
+				lines[keyPath] = lineAt()
+				if err := walk(keyPath); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(joinIndex(path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	walk("")
+	return lines
+}
+
+var yamlKeyPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"\-\[\]{}][^:#]*?|-[^\s:#][^:#]*?)\s*:(?:\s+(.*))?$`)
+
+type yamlFrame struct {
+	indent int
+	path   string
+	seq    bool
+	next   int
+}
+
+// yamlLines approximately maps the paths defined in a YAML document to their
+// line numbers. It understands block mappings and sequences; values in flow
+// style or spanning several lines are attributed to the line of their key.
+func yamlLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	stack := []*yamlFrame{{indent: -1}}
+	pending := ""
+	blockScalarIndent := -1
+	for n, line := range strings.Split(string(data), "\n") {
+		content := strings.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+		content = strings.TrimRight(content, " \t\r")
+		if blockScalarIndent >= 0 {
+			if content == "" || indent > blockScalarIndent {
+				continue
+			}
+			blockScalarIndent = -1
+		}
+		if content == "" || strings.HasPrefix(content, "#") || strings.HasPrefix(content, "---") || strings.HasPrefix(content, "...") {
+			continue
+		}
+		for len(stack) > 1 && stack[len(stack)-1].indent > indent {
+			stack = stack[:len(stack)-1]
+		}
+		top := stack[len(stack)-1]
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			if !(top.seq && top.indent == indent) {
+				top = &yamlFrame{indent: indent, path: pending, seq: true}
+				stack = append(stack, top)
+			}
+			path := joinIndex(top.path, top.next)
+			top.next++
+			lines[path] = n + 1
+			pending = path
+			rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+			if rest == "" || yamlKeyPattern.FindStringSubmatch(rest) == nil {
+				continue
+			}
+			indent = len(line) - len(rest)
+			top = &yamlFrame{indent: indent, path: path}
+			stack = append(stack, top)
+			content = rest
+		}
+		match := yamlKeyPattern.FindStringSubmatch(content)
+		if match == nil {
+			continue
+		}
+		for len(stack) > 1 && stack[len(stack)-1].indent == indent && stack[len(stack)-1].seq {
+			stack = stack[:len(stack)-1]
+		}
+		top = stack[len(stack)-1]
+		if top.indent < indent {
+			top = &yamlFrame{indent: indent, path: pending}
+			stack = append(stack, top)
+		}
+		key := strings.Trim(match[1], `"'`)
+		path := joinKey(top.path, key)
+		lines[path] = n + 1
+		pending = path
+		value := strings.TrimSpace(match[2])
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			blockScalarIndent = indent
+		}
+	}
+	return lines
+}
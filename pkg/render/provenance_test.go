@@ -0,0 +1,72 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestYAMLLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		lines map[string]int
+	}{
+		{
+			name:  "mappings",
+			data:  "a: 1\nb:\n  c: 2\n\n  d:\n    e: 3\nf: 4\n",
+			lines: map[string]int{"a": 1, "b": 2, "b.c": 3, "b.d": 5, "b.d.e": 6, "f": 7},
+		},
+		{
+			name:  "comments and document markers",
+			data:  "---\n# a: 0\na: 1 # comment\n...\n",
+			lines: map[string]int{"a": 3},
+		},
+		{
+			name:  "sequences",
+			data:  "a:\n  - x\n  - y\nb:\n- 1\n- 2\n",
+			lines: map[string]int{"a": 1, "a[0]": 2, "a[1]": 3, "b": 4, "b[0]": 5, "b[1]": 6},
+		},
+		{
+			name:  "sequence of mappings",
+			data:  "items:\n  - name: a\n    value: 1\n  - name: b\n    nested:\n      x: 2\nnext: 3\n",
+			lines: map[string]int{"items": 1, "items[0]": 2, "items[0].name": 2, "items[0].value": 3, "items[1]": 4, "items[1].name": 4, "items[1].nested": 5, "items[1].nested.x": 6, "next": 7},
+		},
+		{
+			name:  "block scalars",
+			data:  "a: |\n  line: 1\n  line: 2\nb: >-\n  folded: x\nc: 3\n",
+			lines: map[string]int{"a": 1, "b": 4, "c": 6},
+		},
+		{
+			name:  "flow style",
+			data:  "a: {b: 1, c: 2}\nd: [1, 2]\n",
+			lines: map[string]int{"a": 1, "d": 2},
+		},
+		{
+			name:  "quoted keys",
+			data:  "\"a.b\": 1\n'c d': 2\n",
+			lines: map[string]int{`["a.b"]`: 1, `["c d"]`: 2},
+		},
+		{
+			name:  "CRLF",
+			data:  "a:\r\n  b: 1\r\n",
+			lines: map[string]int{"a": 1, "a.b": 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := yamlLines([]byte(test.data))
+			if !reflect.DeepEqual(lines, test.lines) {
+				t.Errorf("expected %v, got %v", test.lines, lines)
+			}
+		})
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	data := "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": [\n      1,\n      {\"d\": 2}\n    ]\n  },\n  \"e.f\": null\n}\n"
+	expected := map[string]int{"a": 2, "b": 3, "b.c": 4, "b.c[0]": 5, "b.c[1]": 6, "b.c[1].d": 6, `["e.f"]`: 9}
+	lines := jsonLines([]byte(data))
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
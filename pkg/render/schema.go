@@ -0,0 +1,526 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SchemaError is a single violation of a JSON Schema.
+type SchemaError struct {
+	Pointer string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "(root)"
+	}
+	return fmt.Sprintf("%s: %s", pointer, e.Message)
+}
+
+// SchemaErrors is the list of all violations found when validating a value
+// against a JSON Schema.
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "schema validation failed: " + strings.Join(messages, "; ")
+}
+
+// Schema is a JSON Schema (a subset of draft 7), loaded from a JSON, YAML or TOML file.
+type Schema struct {
+	root interface{}
+}
+
+// LoadSchema loads a JSON Schema from a JSON, YAML or TOML file.
+func LoadSchema(path string) (*Schema, error) {
+	raw := Vars{}
+	err := VarsSourceFile{Path: path}.Load(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: flatten(map[string]interface{}(raw))}, nil
+}
+
+// ApplyDefaults sets the default value of each property defined in the
+// schema that is missing from vars.
+func (s *Schema) ApplyDefaults(vars Vars) {
+	s.applyDefaults(s.root, map[string]interface{}(vars), 0)
+}
+
+// Validate checks vars against the schema and returns all violations.
+func (s *Schema) Validate(vars Vars) error {
+	errs := s.validate(s.root, map[string]interface{}(vars), "", 0)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+const maxSchemaDepth = 64
+
+func escapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func unescapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+}
+
+// resolve follows $ref (local references only) until it reaches a schema without one.
+func (s *Schema) resolve(schema interface{}) (map[string]interface{}, error) {
+	for depth := 0; ; depth++ {
+		m, ok := asMap(schema)
+		if !ok {
+			return nil, nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m, nil
+		}
+		if depth > maxSchemaDepth {
+			return nil, fmt.Errorf("$ref %q: too many levels of indirection", ref)
+		}
+		if !strings.HasPrefix(ref, "#") {
+			return nil, fmt.Errorf("$ref %q: only references within the schema are supported", ref)
+		}
+		schema = s.root
+		for _, token := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(ref, "#"), "/"), "/") {
+			if token == "" {
+				continue
+			}
+			token = unescapePointer(token)
+			if m, ok := asMap(schema); ok {
+				schema, ok = m[token]
+				if !ok {
+					return nil, fmt.Errorf("$ref %q: not found", ref)
+				}
+			} else {
+				return nil, fmt.Errorf("$ref %q: not found", ref)
+			}
+		}
+	}
+}
+
+func deepCopy(value interface{}) interface{} {
+	if m, ok := asMap(value); ok {
+		result := map[string]interface{}{}
+		for k, v := range m {
+			result[k] = deepCopy(v)
+		}
+		return result
+	}
+	if s, ok := value.([]interface{}); ok {
+		result := make([]interface{}, len(s))
+		for i, v := range s {
+			result[i] = deepCopy(v)
+		}
+		return result
+	}
+	return value
+}
+
+func (s *Schema) applyDefaults(schema interface{}, value interface{}, depth int) {
+	if depth > maxSchemaDepth {
+		return
+	}
+	m, err := s.resolve(schema)
+	if err != nil || m == nil {
+		return
+	}
+	if subschemas, ok := m["allOf"].([]interface{}); ok {
+		for _, subschema := range subschemas {
+			s.applyDefaults(subschema, value, depth+1)
+		}
+	}
+	if object, ok := asMap(value); ok {
+		properties, _ := asMap(m["properties"])
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, err := s.resolve(properties[name])
+			if err != nil || property == nil {
+				continue
+			}
+			if _, ok := object[name]; !ok {
+				if defaultValue, ok := property["default"]; ok {
+					object[name] = deepCopy(defaultValue)
+				}
+			}
+			if child, ok := object[name]; ok {
+				s.applyDefaults(property, child, depth+1)
+			}
+		}
+	}
+	if list, ok := value.([]interface{}); ok {
+		if items, ok := asMap(m["items"]); ok {
+			for _, item := range list {
+				s.applyDefaults(items, item, depth+1)
+			}
+		}
+	}
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func schemaType(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	if _, ok := asMap(value); ok {
+		return "object"
+	}
+	if n, ok := toNumber(value); ok {
+		if n == math.Trunc(n) && !math.IsInf(n, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	switch value.(type) {
+	case []interface{}, []string:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func hasType(value interface{}, typeName string) bool {
+	actual := schemaType(value)
+	return actual == typeName || (typeName == "number" && actual == "integer")
+}
+
+// schemaEqual compares values as JSON would, so that 1 (int) equals 1.0 (float64).
+func schemaEqual(a, b interface{}) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x == y
+	}
+	if x, ok := asMap(a); ok {
+		y, ok := asMap(b)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !schemaEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := a.([]interface{}); ok {
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !schemaEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func asList(value interface{}) ([]interface{}, bool) {
+	switch list := value.(type) {
+	case []interface{}:
+		return list, true
+	case []string:
+		result := make([]interface{}, len(list))
+		for i, s := range list {
+			result[i] = s
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+func (s *Schema) validate(schema interface{}, value interface{}, pointer string, depth int) SchemaErrors {
+	fail := func(format string, args ...interface{}) SchemaErrors {
+		return SchemaErrors{{Pointer: pointer, Message: fmt.Sprintf(format, args...)}}
+	}
+	if depth > maxSchemaDepth {
+		return fail("schema nested too deeply")
+	}
+	if b, ok := schema.(bool); ok {
+		if !b {
+			return fail("no value is allowed here")
+		}
+		return nil
+	}
+	m, err := s.resolve(schema)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if m == nil {
+		return nil
+	}
+	errs := SchemaErrors{}
+
+	switch t := m["type"].(type) {
+	case string:
+		if !hasType(value, t) {
+			return fail("expected %s, got %s", t, schemaType(value))
+		}
+	case []interface{}:
+		names := []string{}
+		matched := false
+		for _, name := range t {
+			names = append(names, fmt.Sprint(name))
+			if hasType(value, fmt.Sprint(name)) {
+				matched = true
+			}
+		}
+		if !matched {
+			return fail("expected %s, got %s", strings.Join(names, " or "), schemaType(value))
+		}
+	}
+
+	if enum, ok := m["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if schemaEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed, _ := json.Marshal(enum)
+			errs = append(errs, fail("must be one of %s", allowed)...)
+		}
+	}
+	if constant, ok := m["const"]; ok && !schemaEqual(value, constant) {
+		expected, _ := json.Marshal(constant)
+		errs = append(errs, fail("must be %s", expected)...)
+	}
+
+	if str, ok := value.(string); ok {
+		length := len([]rune(str))
+		if n, ok := toNumber(m["minLength"]); ok && float64(length) < n {
+			errs = append(errs, fail("must be at least %v characters long", n)...)
+		}
+		if n, ok := toNumber(m["maxLength"]); ok && float64(length) > n {
+			errs = append(errs, fail("must be at most %v characters long", n)...)
+		}
+		if pattern, ok := m["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				errs = append(errs, fail("invalid pattern %q: %v", pattern, err)...)
+			} else if !re.MatchString(str) {
+				errs = append(errs, fail("must match pattern %q", pattern)...)
+			}
+		}
+	}
+
+	if number, ok := toNumber(value); ok {
+		if n, ok := toNumber(m["minimum"]); ok {
+			if exclusive, _ := m["exclusiveMinimum"].(bool); exclusive && number <= n {
+				errs = append(errs, fail("must be greater than %v", n)...)
+			} else if number < n {
+				errs = append(errs, fail("must be at least %v", n)...)
+			}
+		}
+		if n, ok := toNumber(m["maximum"]); ok {
+			if exclusive, _ := m["exclusiveMaximum"].(bool); exclusive && number >= n {
+				errs = append(errs, fail("must be less than %v", n)...)
+			} else if number > n {
+				errs = append(errs, fail("must be at most %v", n)...)
+			}
+		}
+		if n, ok := toNumber(m["exclusiveMinimum"]); ok && number <= n {
+			errs = append(errs, fail("must be greater than %v", n)...)
+		}
+		if n, ok := toNumber(m["exclusiveMaximum"]); ok && number >= n {
+			errs = append(errs, fail("must be less than %v", n)...)
+		}
+		if n, ok := toNumber(m["multipleOf"]); ok && n > 0 {
+			if q := number / n; q != math.Trunc(q) {
+				errs = append(errs, fail("must be a multiple of %v", n)...)
+			}
+		}
+	}
+
+	if list, ok := asList(value); ok {
+		if n, ok := toNumber(m["minItems"]); ok && float64(len(list)) < n {
+			errs = append(errs, fail("must have at least %v items", n)...)
+		}
+		if n, ok := toNumber(m["maxItems"]); ok && float64(len(list)) > n {
+			errs = append(errs, fail("must have at most %v items", n)...)
+		}
+		if unique, _ := m["uniqueItems"].(bool); unique {
+		outer:
+			for i := range list {
+				for j := 0; j < i; j++ {
+					if schemaEqual(list[i], list[j]) {
+						errs = append(errs, fail("items %d and %d are equal, but items must be unique", j, i)...)
+						break outer
+					}
+				}
+			}
+		}
+		switch items := m["items"].(type) {
+		case []interface{}:
+			for i, item := range list {
+				itemPointer := fmt.Sprintf("%s/%d", pointer, i)
+				if i < len(items) {
+					errs = append(errs, s.validate(items[i], item, itemPointer, depth+1)...)
+				} else if additional, ok := m["additionalItems"]; ok {
+					errs = append(errs, s.validate(additional, item, itemPointer, depth+1)...)
+				}
+			}
+		case nil:
+		default:
+			for i, item := range list {
+				errs = append(errs, s.validate(items, item, fmt.Sprintf("%s/%d", pointer, i), depth+1)...)
+			}
+		}
+		if contains, ok := m["contains"]; ok {
+			found := false
+			for i, item := range list {
+				if len(s.validate(contains, item, fmt.Sprintf("%s/%d", pointer, i), depth+1)) == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, fail("must contain an item matching the \"contains\" schema")...)
+			}
+		}
+	}
+
+	if object, ok := asMap(value); ok {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if required, ok := m["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := object[fmt.Sprint(name)]; !ok {
+					errs = append(errs, fail("missing required property %q", name)...)
+				}
+			}
+		}
+		if n, ok := toNumber(m["minProperties"]); ok && float64(len(object)) < n {
+			errs = append(errs, fail("must have at least %v properties", n)...)
+		}
+		if n, ok := toNumber(m["maxProperties"]); ok && float64(len(object)) > n {
+			errs = append(errs, fail("must have at most %v properties", n)...)
+		}
+		properties, _ := asMap(m["properties"])
+		patternProperties, _ := asMap(m["patternProperties"])
+		additional, hasAdditional := m["additionalProperties"]
+		for _, key := range keys {
+			propertyPointer := pointer + "/" + escapePointer(key)
+			matched := false
+			if property, ok := properties[key]; ok {
+				matched = true
+				errs = append(errs, s.validate(property, object[key], propertyPointer, depth+1)...)
+			}
+			for pattern, property := range patternProperties {
+				re, err := regexp.Compile(pattern)
+				if err == nil && re.MatchString(key) {
+					matched = true
+					errs = append(errs, s.validate(property, object[key], propertyPointer, depth+1)...)
+				}
+			}
+			if !matched && hasAdditional {
+				if allowed, ok := additional.(bool); ok && !allowed {
+					errs = append(errs, SchemaError{Pointer: propertyPointer, Message: "additional property is not allowed"})
+				} else {
+					errs = append(errs, s.validate(additional, object[key], propertyPointer, depth+1)...)
+				}
+			}
+		}
+	}
+
+	if subschemas, ok := m["allOf"].([]interface{}); ok {
+		for _, subschema := range subschemas {
+			errs = append(errs, s.validate(subschema, value, pointer, depth+1)...)
+		}
+	}
+	if subschemas, ok := m["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, subschema := range subschemas {
+			if len(s.validate(subschema, value, pointer, depth+1)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fail("must match at least one schema in \"anyOf\"")...)
+		}
+	}
+	if subschemas, ok := m["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, subschema := range subschemas {
+			if len(s.validate(subschema, value, pointer, depth+1)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			errs = append(errs, fail("must match exactly one schema in \"oneOf\", but matches %d", matches)...)
+		}
+	}
+	if not, ok := m["not"]; ok && len(s.validate(not, value, pointer, depth+1)) == 0 {
+		errs = append(errs, fail("must not match the \"not\" schema")...)
+	}
+	if condition, ok := m["if"]; ok {
+		if len(s.validate(condition, value, pointer, depth+1)) == 0 {
+			if then, ok := m["then"]; ok {
+				errs = append(errs, s.validate(then, value, pointer, depth+1)...)
+			}
+		} else if otherwise, ok := m["else"]; ok {
+			errs = append(errs, s.validate(otherwise, value, pointer, depth+1)...)
+		}
+	}
+	return errs
+}
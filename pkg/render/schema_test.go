@@ -0,0 +1,120 @@
+package render
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func testSchema(t *testing.T, text string) *Schema {
+	var root interface{}
+	err := json.Unmarshal([]byte(text), &root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Schema{root: root}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		vars   string
+		errs   []string
+	}{
+		{"type", `{"properties": {"a": {"type": "string"}}}`, `{"a": 1}`, []string{"/a: expected string, got integer"}},
+		{"type list", `{"properties": {"a": {"type": ["string", "null"]}}}`, `{"a": null}`, nil},
+		{"type list mismatch", `{"properties": {"a": {"type": ["string", "null"]}}}`, `{"a": true}`, []string{"/a: expected string or null, got boolean"}},
+		{"integer", `{"properties": {"a": {"type": "integer"}}}`, `{"a": 1.5}`, []string{"/a: expected integer, got number"}},
+		{"enum", `{"properties": {"a": {"enum": ["x", 1]}}}`, `{"a": 1}`, nil},
+		{"enum mismatch", `{"properties": {"a": {"enum": ["x", 1]}}}`, `{"a": "y"}`, []string{`/a: must be one of ["x",1]`}},
+		{"const", `{"properties": {"a": {"const": {"b": 1}}}}`, `{"a": {"b": 2}}`, []string{`/a: must be {"b":1}`}},
+		{"minLength", `{"properties": {"a": {"minLength": 2}}}`, `{"a": "ä"}`, []string{"/a: must be at least 2 characters long"}},
+		{"maxLength", `{"properties": {"a": {"maxLength": 2}}}`, `{"a": "äöü"}`, []string{"/a: must be at most 2 characters long"}},
+		{"pattern", `{"properties": {"a": {"pattern": "^[a-z]+$"}}}`, `{"a": "A"}`, []string{`/a: must match pattern "^[a-z]+$"`}},
+		{"minimum", `{"properties": {"a": {"minimum": 1}}}`, `{"a": 0}`, []string{"/a: must be at least 1"}},
+		{"maximum", `{"properties": {"a": {"maximum": 1}}}`, `{"a": 1}`, nil},
+		{"exclusiveMinimum", `{"properties": {"a": {"exclusiveMinimum": 1}}}`, `{"a": 1}`, []string{"/a: must be greater than 1"}},
+		{"exclusiveMaximum (draft 4)", `{"properties": {"a": {"maximum": 1, "exclusiveMaximum": true}}}`, `{"a": 1}`, []string{"/a: must be less than 1"}},
+		{"multipleOf", `{"properties": {"a": {"multipleOf": 0.5}}}`, `{"a": 1.25}`, []string{"/a: must be a multiple of 0.5"}},
+		{"minItems", `{"properties": {"a": {"minItems": 1}}}`, `{"a": []}`, []string{"/a: must have at least 1 items"}},
+		{"maxItems", `{"properties": {"a": {"maxItems": 1}}}`, `{"a": [1, 2]}`, []string{"/a: must have at most 1 items"}},
+		{"uniqueItems", `{"properties": {"a": {"uniqueItems": true}}}`, `{"a": [1, 2, 1.0]}`, []string{"/a: items 0 and 2 are equal, but items must be unique"}},
+		{"items", `{"properties": {"a": {"items": {"type": "string"}}}}`, `{"a": ["x", 1]}`, []string{"/a/1: expected string, got integer"}},
+		{"tuple items", `{"properties": {"a": {"items": [{"type": "string"}], "additionalItems": false}}}`, `{"a": ["x", 1]}`, []string{"/a/1: no value is allowed here"}},
+		{"contains", `{"properties": {"a": {"contains": {"const": 2}}}}`, `{"a": [1]}`, []string{`/a: must contain an item matching the "contains" schema`}},
+		{"required", `{"required": ["a", "b"]}`, `{"a": 1}`, []string{`(root): missing required property "b"`}},
+		{"minProperties", `{"minProperties": 2}`, `{"a": 1}`, []string{"(root): must have at least 2 properties"}},
+		{"maxProperties", `{"maxProperties": 0}`, `{"a": 1}`, []string{"(root): must have at most 0 properties"}},
+		{"patternProperties", `{"patternProperties": {"^x": {"type": "string"}}}`, `{"xa": 1, "a": 1}`, []string{"/xa: expected string, got integer"}},
+		{"additionalProperties", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b/c": 1}`, []string{"/b~1c: additional property is not allowed"}},
+		{"additionalProperties schema", `{"additionalProperties": {"type": "string"}}`, `{"a": 1}`, []string{"/a: expected string, got integer"}},
+		{"allOf", `{"allOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{}`, []string{`(root): missing required property "a"`, `(root): missing required property "b"`}},
+		{"anyOf", `{"anyOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{"b": 1}`, nil},
+		{"anyOf mismatch", `{"anyOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{}`, []string{`(root): must match at least one schema in "anyOf"`}},
+		{"oneOf", `{"oneOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{"a": 1, "b": 1}`, []string{`(root): must match exactly one schema in "oneOf", but matches 2`}},
+		{"not", `{"not": {"required": ["a"]}}`, `{"a": 1}`, []string{`(root): must not match the "not" schema`}},
+		{"if then", `{"if": {"required": ["a"]}, "then": {"required": ["b"]}, "else": {"required": ["c"]}}`, `{"a": 1}`, []string{`(root): missing required property "b"`}},
+		{"if else", `{"if": {"required": ["a"]}, "then": {"required": ["b"]}, "else": {"required": ["c"]}}`, `{}`, []string{`(root): missing required property "c"`}},
+		{"$ref", `{"definitions": {"port": {"type": "integer"}}, "properties": {"a": {"$ref": "#/definitions/port"}}}`, `{"a": "x"}`, []string{"/a: expected integer, got string"}},
+		{"external $ref", `{"properties": {"a": {"$ref": "other.json"}}}`, `{"a": 1}`, []string{`/a: $ref "other.json": only references within the schema are supported`}},
+		{"recursive $ref", `{"properties": {"a": {"$ref": "#/properties/a"}}}`, `{"a": 1}`, []string{`/a: $ref "#/properties/a": too many levels of indirection`}},
+		{"false schema", `{"properties": {"a": false}}`, `{"a": 1}`, []string{"/a: no value is allowed here"}},
+		{"several errors", `{"properties": {"a": {"type": "string"}, "b": {"minimum": 1}}}`, `{"a": 1, "b": 0}`, []string{"/a: expected string, got integer", "/b: must be at least 1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema := testSchema(t, test.schema)
+			vars := Vars{}
+			err := json.Unmarshal([]byte(test.vars), &vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var errs []string
+			if err := schema.Validate(vars); err != nil {
+				for _, schemaErr := range err.(SchemaErrors) {
+					errs = append(errs, schemaErr.Error())
+				}
+			}
+			if !reflect.DeepEqual(errs, test.errs) {
+				t.Errorf("expected %q, got %q", test.errs, errs)
+			}
+		})
+	}
+}
+
+func TestSchemaValidateYAMLValues(t *testing.T) {
+	schema := testSchema(t, `{"properties": {"a": {"type": "integer", "enum": [1, 2]}, "b": {"type": "object", "required": ["c"]}}}`)
+	vars := Vars{"a": 2, "b": map[string]interface{}{"c": true}}
+	if err := schema.Validate(vars); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaApplyDefaults(t *testing.T) {
+	schema := testSchema(t, `{
+		"definitions": {"port": {"type": "integer", "default": 80}},
+		"properties": {
+			"a": {"default": "x"},
+			"b": {"default": "y"},
+			"server": {
+				"default": {},
+				"properties": {"port": {"$ref": "#/definitions/port"}}
+			},
+			"list": {"items": {"properties": {"c": {"default": true}}}}
+		},
+		"allOf": [{"properties": {"d": {"default": [1]}}}]
+	}`)
+	vars := Vars{"a": "set", "list": []interface{}{map[string]interface{}{}, map[string]interface{}{"c": false}}}
+	schema.ApplyDefaults(vars)
+	expected := Vars{
+		"a":      "set",
+		"b":      "y",
+		"server": map[string]interface{}{"port": 80.0},
+		"list":   []interface{}{map[string]interface{}{"c": true}, map[string]interface{}{"c": false}},
+		"d":      []interface{}{1.0},
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("expected %v, got %v", expected, vars)
+	}
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Server is an http.Handler that renders the template named by the request
+// path. The template's variables are overridden by the request's query
+// parameters, or by the fields of its body if it is a JSON object.
+type Server struct {
+	mu        sync.RWMutex
+	templates *Templates
+}
+
+// SetTemplates replaces the templates served by the server.
+func (s *Server) SetTemplates(templates *Templates) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates = templates
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.RLock()
+	templates := s.templates
+	s.mu.RUnlock()
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if templates == nil || templates.Lookup(name) == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	vars := map[string]interface{}{}
+	for key, value := range templates.Vars {
+		vars[key] = value
+	}
+	for key, values := range r.URL.Query() {
+		if len(values) == 1 {
+			vars[key] = values[0]
+		} else {
+			vars[key] = values
+		}
+	}
+	if r.Method == http.MethodPost && r.Body != nil {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/json" {
+			body := map[string]interface{}{}
+			err := json.NewDecoder(r.Body).Decode(&body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for key, value := range body {
+				vars[key] = value
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := templates.Execute(name, vars, buf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
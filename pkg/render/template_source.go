@@ -4,11 +4,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"text/template"
+	"strings"
+
+	"github.com/gobwas/glob"
 )
 
 type TemplateSource struct {
 	Name          string
+	ForEach       *TemplateForEach         `json:",omitempty"`
+	FromDir       *TemplateSourceDir       `json:",omitempty"`
 	FromEnv       *TemplateSourceEnv       `json:",omitempty"`
 	FromFile      *TemplateSourceFile      `json:",omitempty"`
 	FromFileGlob  *TemplateSourceFileGlob  `json:",omitempty"`
@@ -16,21 +20,24 @@ type TemplateSource struct {
 	FromStdin     *TemplateSourceStdin     `json:",omitempty"`
 }
 
-func (ts *TemplateSource) Load(funcs template.FuncMap, t *template.Template) ([]string, error) {
+func (ts *TemplateSource) Load(t *Templates) ([]string, error) {
+	if ts.FromDir != nil {
+		return ts.FromDir.Load(t, ts.Name)
+	}
 	if ts.FromEnv != nil {
-		return ts.FromEnv.Load(funcs, ts.Name, t)
+		return ts.FromEnv.Load(t, ts.Name)
 	}
 	if ts.FromFile != nil {
-		return ts.FromFile.Load(funcs, ts.Name, t)
+		return ts.FromFile.Load(t, ts.Name)
 	}
 	if ts.FromFileGlob != nil {
-		return ts.FromFileGlob.Load(funcs, ts.Name, t)
+		return ts.FromFileGlob.Load(t, ts.Name)
 	}
 	if ts.FromParameter != nil {
-		return ts.FromParameter.Load(funcs, ts.Name, t)
+		return ts.FromParameter.Load(t, ts.Name)
 	}
 	if ts.FromStdin != nil {
-		return ts.FromStdin.Load(funcs, ts.Name, t)
+		return ts.FromStdin.Load(t, ts.Name)
 	}
 	return nil, nil
 }
@@ -39,35 +46,32 @@ type TemplateSourceParameter struct {
 	Value string
 }
 
-func (ts *TemplateSourceParameter) Load(funcs template.FuncMap, name string, t *template.Template) ([]string, error) {
-	t = t.New(name).Funcs(funcs)
-	setupTemplate(t)
-	_, err := t.Parse(ts.Value)
-	return []string{name}, err
+func (ts *TemplateSourceParameter) Load(t *Templates, name string) ([]string, error) {
+	return []string{name}, t.define(name, ts.Value, nil, 0)
 }
 
 type TemplateSourceStdin struct{}
 
-func (ts *TemplateSourceStdin) Load(funcs template.FuncMap, name string, t *template.Template) ([]string, error) {
+func (ts *TemplateSourceStdin) Load(t *Templates, name string) ([]string, error) {
 	bytes, err := ioutil.ReadAll(os.Stdin)
-	t = t.New(name).Funcs(funcs)
-	setupTemplate(t)
-	_, err = t.Parse(string(bytes))
-	return []string{name}, err
+	if err != nil {
+		return nil, err
+	}
+	return []string{name}, t.define(name, string(bytes), nil, 0)
 }
 
 type TemplateSourceFileGlob struct {
 	Glob string
 }
 
-func (ts *TemplateSourceFileGlob) Load(funcs template.FuncMap, name string, t *template.Template) ([]string, error) {
+func (ts *TemplateSourceFileGlob) Load(t *Templates, name string) ([]string, error) {
 	paths, err := filepath.Glob(ts.Glob)
 	if err != nil {
 		return nil, err
 	}
 	for _, path := range paths {
 		tsf := &TemplateSourceFile{Path: path}
-		_, err := tsf.Load(funcs, path, t)
+		_, err := tsf.Load(t, path)
 		if err != nil {
 			return nil, err
 		}
@@ -75,35 +79,114 @@ func (ts *TemplateSourceFileGlob) Load(funcs template.FuncMap, name string, t *t
 	return paths, nil
 }
 
-type TemplateSourceFile struct {
-	Path string
+// TemplateSourceDir loads all files below a directory, naming each
+// template by its slash-separated path relative to the directory. Files whose
+// names start with "_" are loaded as partials.
+type TemplateSourceDir struct {
+	Path    string
+	Include string `json:",omitempty"`
+	Exclude string `json:",omitempty"`
 }
 
-func (ts *TemplateSourceFile) Load(funcs template.FuncMap, name string, t *template.Template) ([]string, error) {
-	f, err := os.Open(ts.Path)
+func (ts *TemplateSourceDir) Load(t *Templates, name string) ([]string, error) {
+	names := []string{}
+	err := ts.walk(func(path, relPath string, info os.FileInfo) error {
+		tsf := &TemplateSourceFile{Path: path}
+		if strings.HasPrefix(info.Name(), "_") {
+			return t.loadPartials(func() error {
+				_, err := tsf.Load(t, relPath)
+				return err
+			})
+		}
+		_, err := tsf.Load(t, relPath)
+		if err != nil {
+			return err
+		}
+		names = append(names, relPath)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	return names, nil
+}
+
+// walk calls fn for each file below the directory that matches Include and
+// does not match Exclude, with its slash-separated path relative to the
+// directory.
+func (ts *TemplateSourceDir) walk(fn func(path, relPath string, info os.FileInfo) error) error {
+	var include, exclude glob.Glob
+	var err error
+	if ts.Include != "" {
+		include, err = glob.Compile(ts.Include, '/')
+		if err != nil {
+			return err
+		}
+	}
+	if ts.Exclude != "" {
+		exclude, err = glob.Compile(ts.Exclude, '/')
+		if err != nil {
+			return err
+		}
+	}
+	return filepath.Walk(ts.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(ts.Path, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if include != nil && !include.Match(relPath) {
+			return nil
+		}
+		if exclude != nil && exclude.Match(relPath) {
+			return nil
+		}
+		return fn(path, relPath, info)
+	})
+}
 
+type TemplateSourceFile struct {
+	Path string
+}
+
+func (ts *TemplateSourceFile) Load(t *Templates, name string) ([]string, error) {
 	bytes, err := ioutil.ReadFile(ts.Path)
 	if err != nil {
 		return nil, err
 	}
-
-	t = t.New(name).Funcs(funcs)
-	setupTemplate(t)
-	_, err = t.Parse(string(bytes))
-	return []string{name}, err
+	info, err := os.Stat(ts.Path)
+	if err != nil {
+		return nil, err
+	}
+	if t.isAsset(name, bytes) {
+		t.defineAsset(name, bytes, info.Mode().Perm())
+		t.sources[name] = ts.Path
+		return []string{name}, nil
+	}
+	frontMatter, frontMatterLines, text, err := t.parseFrontMatter(ts.Path, bytes)
+	if err != nil {
+		return nil, err
+	}
+	frontMatter.resolveLayout(ts.Path)
+	err = t.define(name, text, frontMatter, frontMatterLines)
+	if err != nil {
+		return nil, err
+	}
+	t.Modes[name] = info.Mode().Perm()
+	t.sources[name] = ts.Path
+	return []string{name}, nil
 }
 
 type TemplateSourceEnv struct {
 	Key string
 }
 
-func (ts *TemplateSourceEnv) Load(funcs template.FuncMap, name string, t *template.Template) ([]string, error) {
-	t = t.New(name).Funcs(funcs)
-	setupTemplate(t)
-	_, err := t.Parse(os.Getenv(ts.Key))
-	return []string{name}, err
+func (ts *TemplateSourceEnv) Load(t *Templates, name string) ([]string, error) {
+	return []string{name}, t.define(name, os.Getenv(ts.Key), nil, 0)
 }
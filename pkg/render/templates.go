@@ -1,47 +1,459 @@
 package render
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"regexp"
+	"strings"
 	"text/template"
 
 	"github.com/gobwas/glob"
 )
 
 type Templates struct {
-	Root    *template.Template
-	Funcs   template.FuncMap
-	Vars    map[string]interface{}
-	Names   []string
-	Exclude glob.Glob
+	Root            *template.Template
+	Funcs           template.FuncMap
+	Vars            map[string]interface{}
+	Names           []string
+	ForEach         map[string]*forEach
+	FrontMatter     map[string]*FrontMatter
+	Assets          map[string]*asset
+	Modes           map[string]os.FileMode
+	OutModes        []*outMode
+	DirMode         os.FileMode
+	Copy            []glob.Glob
+	Exclude         glob.Glob
+	Strict          bool
+	Prune           bool
+	OutName         *template.Template
+	StripSuffix     bool
+	ReadFrontMatter bool
+	LeftDelim       string
+	RightDelim      string
+	Layout          string
+	Mode            string
+
+	pages           map[string]*page
+	layouts         map[string]string
+	partials        map[string]bool
+	loadingPartials bool
+	sources         map[string]string
+	hook            loadHook
+}
+
+// TemplateSuffixes are stripped from output names if StripSuffix is set.
+var TemplateSuffixes = []string{".tmpl", ".gotmpl", ".tpl"}
+
+// outsideDir reports whether the clean slash-separated path name refers to a
+// location outside of the directory it is relative to.
+func outsideDir(name string) bool {
+	return path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../")
+}
+
+// outputName returns the path below the output directory that the named
+// template is rendered to.
+func (t *Templates) outputName(name string) (string, error) {
+	dir, base := path.Dir(name), path.Base(name)
+	if t.StripSuffix {
+		for _, suffix := range TemplateSuffixes {
+			if strings.HasSuffix(base, suffix) && base != suffix {
+				base = strings.TrimSuffix(base, suffix)
+				break
+			}
+		}
+	}
+	outName := t.OutName
+	if frontMatter := t.FrontMatter[name]; frontMatter != nil && frontMatter.Output != "" {
+		outName = template.New(name+" (output name)").Delims(t.LeftDelim, t.RightDelim).Funcs(t.Funcs)
+		t.setupTemplate(outName)
+		_, err := outName.Parse(frontMatter.Output)
+		if err != nil {
+			return "", err
+		}
+	}
+	if outName == nil {
+		return path.Join(dir, base), nil
+	}
+	vars := t.templateVars(name)
+	vars["Template"] = map[string]interface{}{
+		"Name": name,
+		"Dir":  dir,
+		"Base": base,
+	}
+	buf := &bytes.Buffer{}
+	err := t.execute(outName, vars, buf)
+	if err != nil {
+		return "", err
+	}
+	outputName := path.Clean(buf.String())
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("template %q: output name is empty", name)
+	}
+	if outsideDir(outputName) {
+		return "", fmt.Errorf("template %q: output name %q is outside the output directory", name, outputName)
+	}
+	return outputName, nil
+}
+
+// MissingKeyError is returned in strict mode when a template
+// refers to a variable that is not defined.
+type MissingKeyError struct {
+	Template string
+	Location string
+	Path     string
+}
+
+func (e *MissingKeyError) Error() string {
+	return fmt.Sprintf("%s: template %q refers to undefined variable %s", e.Location, e.Template, e.Path)
+}
+
+var missingKeyErrorPattern = regexp.MustCompile(`^template: (.*): executing ".*" at <(.*)>: map has no entry for key "(.*)"$`)
+
+// missingKeyError rewrites text/template's missing key errors into
+// MissingKeyErrors, leaving any other error unchanged.
+func missingKeyError(err error) error {
+	execErr, ok := err.(template.ExecError)
+	if !ok {
+		return err
+	}
+	match := missingKeyErrorPattern.FindStringSubmatch(execErr.Err.Error())
+	if match == nil {
+		return err
+	}
+	location, node, key := match[1], match[2], match[3]
+	path := node
+	if i := strings.Index(node+".", "."+key+"."); i >= 0 {
+		path = node[:i+1+len(key)]
+	}
+	return &MissingKeyError{
+		Template: execErr.Name,
+		Location: location,
+		Path:     path,
+	}
+}
+
+// define parses text as the template with the given name, replacing any
+// previous definition. The template's front matter, if any, has been stripped
+// from text and took up the given number of lines.
+func (t *Templates) define(name, text string, frontMatter *FrontMatter, frontMatterLines int) error {
+	delete(t.Assets, name)
+	delete(t.Modes, name)
+	delete(t.sources, name)
+	leftDelim, rightDelim := frontMatter.delims(t.LeftDelim, t.RightDelim)
+	delete(t.pages, name)
+	if t.loadingPartials {
+		t.partials[name] = true
+	} else {
+		delete(t.partials, name)
+	}
+	if frontMatter != nil {
+		t.FrontMatter[name] = frontMatter
+		text = frontMatterPlaceholder(frontMatterLines, leftDelim, rightDelim) + text
+	} else {
+		delete(t.FrontMatter, name)
+	}
+	layout, err := t.pageLayout(name, text, leftDelim, rightDelim, frontMatter)
+	if err != nil {
+		return err
+	}
+	return t.hook.template(t, name, text, leftDelim, rightDelim, layout)
+}
+
+// loadHook receives the templates and layouts as they are loaded. The
+// default hook parses them; in lint mode, they are recorded to be checked
+// all at once instead.
+type loadHook interface {
+	// template is called for each template with the layout it is rendered
+	// within, or "" if it has none.
+	template(t *Templates, name, text, leftDelim, rightDelim, layout string) error
+	// layout is called once for each layout file.
+	layout(t *Templates, path, text string) error
+	// invalid is called for problems with the structure of the named
+	// template found while loading it, and returns the error to fail with.
+	invalid(name string, err error) error
+}
+
+// parseHook is the default loadHook.
+type parseHook struct{}
+
+func (parseHook) template(t *Templates, name, text, leftDelim, rightDelim, layout string) error {
+	if layout != "" {
+		return t.definePage(name, text, leftDelim, rightDelim, layout)
+	}
+	_, err := t.Root.New(name).Delims(leftDelim, rightDelim).Funcs(t.Funcs).Parse(text)
+	return err
+}
+
+func (parseHook) layout(t *Templates, path, text string) error {
+	_, err := template.New(path).Delims(t.LeftDelim, t.RightDelim).Funcs(t.Funcs).Parse(text)
+	return err
+}
+
+func (parseHook) invalid(name string, err error) error {
+	return err
+}
+
+// templateVars returns a copy of the variables with the named template's
+// front matter variables merged in.
+func (t *Templates) templateVars(name string) map[string]interface{} {
+	return t.withFrontMatterVars(name, t.Vars)
+}
+
+func (t *Templates) withFrontMatterVars(name string, vars map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(vars))
+	for key, value := range vars {
+		result[key] = value
+	}
+	if frontMatter := t.FrontMatter[name]; frontMatter != nil {
+		for key, value := range frontMatter.Vars {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// partial reports whether the named template is excluded from output, either
+// by the exclude pattern or by its front matter.
+func (t *Templates) partial(name string) bool {
+	if t.Exclude != nil && t.Exclude.Match(name) {
+		return true
+	}
+	frontMatter := t.FrontMatter[name]
+	return t.partials[name] || (frontMatter != nil && frontMatter.Partial)
+}
+
+// loadPartials calls load, marking all templates it defines as partials: they
+// can be used by other templates, but are not output themselves.
+func (t *Templates) loadPartials(load func() error) error {
+	t.loadingPartials = true
+	defer func() { t.loadingPartials = false }()
+	return load()
+}
+
+func (t *Templates) setupTemplate(tmpl *template.Template) {
+	if t.Strict {
+		tmpl.Option("missingkey=error")
+	} else {
+		tmpl.Option("missingkey=zero")
+	}
+}
+
+// executable is implemented by both text and HTML templates.
+type executable interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+func (t *Templates) execute(tmpl executable, vars interface{}, w io.Writer) error {
+	err := tmpl.Execute(w, vars)
+	if err != nil && t.Strict {
+		return missingKeyError(err)
+	}
+	return err
+}
+
+type output struct {
+	Name     string
+	Template string
+	Content  []byte
+	Mode     os.FileMode
+	Asset    bool
+
+	files []fileStart
+}
+
+// Lookup returns the output template with the given name, or nil if there
+// is no such template or it is excluded from output.
+func (t *Templates) Lookup(name string) *template.Template {
+	for _, templateName := range t.Names {
+		if templateName != name {
+			continue
+		}
+		if t.partial(name) || t.Assets[name] != nil {
+			return nil
+		}
+		template, err := t.outputTemplate(name)
+		if err != nil {
+			return nil
+		}
+		return template
+	}
+	return nil
+}
+
+// Execute renders the named template with the given variables to w.
+func (t *Templates) Execute(name string, vars map[string]interface{}, w io.Writer) error {
+	if t.Lookup(name) == nil {
+		return fmt.Errorf("no such template: %q", name)
+	}
+	e, err := t.newExecution(name)
+	if err != nil {
+		return err
+	}
+	_, err = e.execute(t.withFrontMatterVars(name, vars), w)
+	return err
+}
+
+// outputs renders all non-excluded templates into memory. It is an error for
+// two outputs to have the same name.
+func (t *Templates) outputs() ([]*output, error) {
+	outputs := []*output{}
+	seen := map[string]bool{}
+	written := map[string]*output{}
+	for _, templateName := range t.Names {
+		if seen[templateName] || t.partial(templateName) {
+			continue
+		}
+		seen[templateName] = true
+		templateOutputs, err := t.templateOutputs(templateName)
+		if err != nil {
+			return nil, err
+		}
+		for _, output := range templateOutputs {
+			if previous := written[output.Name]; previous != nil {
+				return nil, fmt.Errorf("templates %q and %q both render to %q", previous.Template, templateName, output.Name)
+			}
+			written[output.Name] = output
+			output.Template = templateName
+			output.Mode, err = t.outputMode(templateName, output.Name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		outputs = append(outputs, templateOutputs...)
+	}
+	return outputs, nil
 }
 
-func setupTemplate(t *template.Template) {
-	t.Option("missingkey=zero")
+// templateOutputs renders the named template into memory.
+func (t *Templates) templateOutputs(name string) ([]*output, error) {
+	if asset := t.Assets[name]; asset != nil {
+		outputName, err := t.outputName(name)
+		if err != nil {
+			return nil, err
+		}
+		return []*output{{Name: outputName, Content: asset.Content, Asset: true}}, nil
+	}
+	e, err := t.newExecution(name)
+	if err != nil {
+		return nil, err
+	}
+	var outputs []*output
+	if forEach := t.ForEach[name]; forEach != nil {
+		outputs, err = t.forEachOutputs(name, e, forEach)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		buf := &bytes.Buffer{}
+		files, err := e.execute(t.templateVars(name), buf)
+		if err != nil {
+			return nil, err
+		}
+		outputName, err := t.outputName(name)
+		if err != nil {
+			return nil, err
+		}
+		outputs = []*output{{Name: outputName, Content: buf.Bytes(), files: files}}
+	}
+	result := []*output{}
+	for _, output := range outputs {
+		files, err := splitFiles(name, output)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, files...)
+	}
+	return result, nil
+}
+
+// Diff renders the templates into memory and compares them against the files
+// RenderToDir would write to dir. It writes a unified diff for each changed
+// file and a line for each file that would be created or whose mode would
+// change to w, and reports whether any file differs.
+func (t *Templates) Diff(dir string, w io.Writer) (bool, error) {
+	outputs, err := t.outputs()
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for _, output := range outputs {
+		outputPath := path.Join(dir, output.Name)
+		info, err := os.Stat(outputPath)
+		if err == nil && output.Mode != 0 && info.Mode().Perm() != output.Mode {
+			changed = true
+			_, err = fmt.Fprintf(w, "would change mode of %s from %04o to %04o\n", outputPath, info.Mode().Perm(), output.Mode)
+			if err != nil {
+				return false, err
+			}
+		}
+		existing, err := ioutil.ReadFile(outputPath)
+		if os.IsNotExist(err) {
+			changed = true
+			_, err = fmt.Fprintf(w, "would create %s\n", outputPath)
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(existing, output.Content) {
+			continue
+		}
+		changed = true
+		if output.Asset {
+			_, err = fmt.Fprintf(w, "binary file %s differs\n", outputPath)
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+		err = unifiedDiff(w, outputPath, outputPath, existing, output.Content)
+		if err != nil {
+			return false, err
+		}
+	}
+	if t.Prune {
+		stale, err := staleOutputs(dir, outputs)
+		if err != nil {
+			return false, err
+		}
+		for _, name := range stale {
+			changed = true
+			_, err = fmt.Fprintf(w, "would remove %s\n", path.Join(dir, name))
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	return changed, nil
 }
 
 func (t *Templates) Render(excludes string, separator string, w io.Writer) error {
 	separatorTemplate := template.New("separator")
 	separatorTemplate.Funcs(t.Funcs)
-	setupTemplate(separatorTemplate)
+	t.setupTemplate(separatorTemplate)
 	_, err := separatorTemplate.Parse(separator)
 	if err != nil {
 		return err
 	}
 
-	n := len(t.Names)
-	for i, templateName := range t.Names {
-		template := t.Root.Lookup(templateName)
-		if t.Exclude != nil && t.Exclude.Match(template.Name()) {
-			continue
-		}
-		err = template.Execute(w, t.Vars)
+	outputs, err := t.outputs()
+	if err != nil {
+		return err
+	}
+	for i, output := range outputs {
+		_, err = w.Write(output.Content)
 		if err != nil {
 			return err
 		}
-		if i < n-1 {
-			err = separatorTemplate.Execute(w, t.Vars)
+		if i < len(outputs)-1 {
+			err = t.execute(separatorTemplate, t.Vars, w)
 			if err != nil {
 				return err
 			}
@@ -50,30 +462,32 @@ func (t *Templates) Render(excludes string, separator string, w io.Writer) error
 	return nil
 }
 
+// RenderToDir renders all templates into memory and then writes each of them
+// to its path below dir. Files whose content would not change are left alone.
 func (t *Templates) RenderToDir(excludes string, dir string) error {
-	for _, templateName := range t.Names {
-		template := t.Root.Lookup(templateName)
-		if t.Exclude != nil && t.Exclude.Match(template.Name()) {
-			continue
-		}
-		templatePath := path.Join(dir, template.Name())
-		templatePathDir := path.Dir(templatePath)
-		err := os.MkdirAll(templatePathDir, 0777|os.ModeDir)
+	outputs, err := t.outputs()
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(outputs))
+	for i, output := range outputs {
+		outputPath := path.Join(dir, output.Name)
+		err := mkdirAll(path.Dir(outputPath), t.DirMode)
 		if err != nil {
 			return err
 		}
-		os.Remove(templatePath)
-		f, err := os.OpenFile(templatePath, os.O_CREATE|os.O_WRONLY, 0777)
-		if err != nil {
-			return err
+		if output.Mode != 0 {
+			_, err = writeFileWithMode(outputPath, output.Content, output.Mode)
+		} else {
+			_, err = writeFileIfChanged(outputPath, output.Content, 0666)
 		}
-		func() {
-			defer f.Close()
-			err = template.Execute(f, t.Vars)
-		}()
 		if err != nil {
 			return err
 		}
+		names[i] = output.Name
+	}
+	if t.Prune {
+		return pruneOutputs(dir, names)
 	}
 	return nil
 }
@@ -84,16 +498,89 @@ func (t *Templates) FromConfig(funcs template.FuncMap, config *Config) error {
 		return err
 	}
 	t.Exclude = exclude
+	if t.hook == nil {
+		t.hook = parseHook{}
+	}
+	t.LeftDelim = config.TemplateLeftDelim
+	t.RightDelim = config.TemplateRightDelim
 	t.Root = template.New("root")
-	t.Root.Delims(config.TemplateLeftDelim, config.TemplateRightDelim)
-	t.Funcs = funcs
+	t.Root.Delims(t.LeftDelim, t.RightDelim)
+	t.Strict = config.TemplateStrict
+	t.Prune = config.TemplateOutPrune
+	t.setupTemplate(t.Root)
+	t.Funcs = template.FuncMap{}
+	for name, f := range funcs {
+		t.Funcs[name] = f
+	}
+	for name, f := range t.includeFuncs() {
+		t.Funcs[name] = f
+	}
+	t.Root.Funcs(t.Funcs)
+	t.StripSuffix = config.TemplateOutStripSuffix
+	if config.TemplateOutName != "" {
+		t.OutName = template.New("output name").Delims(t.LeftDelim, t.RightDelim).Funcs(t.Funcs)
+		t.setupTemplate(t.OutName)
+		_, err := t.OutName.Parse(config.TemplateOutName)
+		if err != nil {
+			return err
+		}
+	}
 	t.Names = []string{}
+	t.ForEach = map[string]*forEach{}
+	t.FrontMatter = map[string]*FrontMatter{}
+	t.ReadFrontMatter = config.TemplateFrontMatter
+	t.Mode = config.TemplateMode
+	err = validateTemplateMode(t.Mode)
+	if err != nil {
+		return err
+	}
+	t.Assets = map[string]*asset{}
+	t.Layout = config.TemplateLayout
+	t.pages = map[string]*page{}
+	t.layouts = map[string]string{}
+	t.partials = map[string]bool{}
+	t.sources = map[string]string{}
+	t.Modes = map[string]os.FileMode{}
+	t.OutModes, err = compileOutModes(config.TemplateOutModes)
+	if err != nil {
+		return err
+	}
+	if config.TemplateOutDirMode != "" {
+		t.DirMode, err = parseMode(config.TemplateOutDirMode)
+		if err != nil {
+			return err
+		}
+	}
+	t.Copy, err = compileGlobs(config.TemplateCopy)
+	if err != nil {
+		return err
+	}
+	for _, partialSource := range config.PartialSources {
+		err := t.loadPartials(func() error {
+			_, err := partialSource.Load(t)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
 	for _, templateSource := range config.TemplateSources {
-		names, err := templateSource.Load(funcs, t.Root)
+		names, err := templateSource.Load(t)
 		if err != nil {
 			return err
 		}
 		t.Names = append(t.Names, names...)
+		for _, name := range names {
+			if templateSource.ForEach == nil {
+				delete(t.ForEach, name)
+				continue
+			}
+			forEach, err := t.newForEach(name, templateSource.ForEach, config.TemplateLeftDelim, config.TemplateRightDelim)
+			if err != nil {
+				return err
+			}
+			t.ForEach[name] = forEach
+		}
 	}
 	return nil
 }
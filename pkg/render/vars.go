@@ -3,12 +3,10 @@ package render
 import (
 	"encoding/json"
 	"io"
-	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
 
-	toml "github.com/BurntSushi/toml"
 	"github.com/gobwas/glob"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -88,45 +86,6 @@ func flatten(value interface{}) interface{} {
 	return value
 }
 
-func (v Vars) fromYAML(r io.Reader) error {
-	bytes, err := ioutil.ReadAll(r)
-	if err != nil {
-		return err
-	}
-	raw := Vars{}
-	err = yaml.Unmarshal(bytes, &raw)
-	if err != nil {
-		return err
-	}
-	v.overwriteWith(flatten(raw).(map[string]interface{}))
-	return nil
-}
-
-func (v Vars) fromTOML(r io.Reader) error {
-	bytes, err := ioutil.ReadAll(r)
-	if err != nil {
-		return err
-	}
-	raw := Vars{}
-	err = toml.Unmarshal(bytes, &raw)
-	if err != nil {
-		return err
-	}
-	v.overwriteWith(flatten(raw).(map[string]interface{}))
-	return nil
-}
-
-func (v Vars) fromJSON(r io.Reader) error {
-	vars := Vars{}
-	dec := json.NewDecoder(r)
-	err := dec.Decode(&vars)
-	if err != nil {
-		return err
-	}
-	v.overwriteWith(vars)
-	return nil
-}
-
 func (v Vars) fromEnvSingle(key string) {
 	v[key] = os.Getenv(key)
 }
@@ -143,11 +102,26 @@ func (v Vars) fromEnv(glob glob.Glob) {
 }
 
 func (v Vars) FromConfig(config *Config) error {
+	merge := config.varsMergeStrategy()
 	for _, varsSource := range config.VarsSources {
-		err := varsSource.Load(v)
+		err := varsSource.Load(v, merge)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return v.applySchema(config)
+}
+
+// applySchema fills in defaults from the config's variable schema, if any,
+// and validates the variables against it.
+func (v Vars) applySchema(config *Config) error {
+	if config.VarsSchema == "" {
+		return nil
+	}
+	schema, err := LoadSchema(config.VarsSchema)
+	if err != nil {
+		return err
+	}
+	schema.ApplyDefaults(v)
+	return schema.Validate(v)
 }
@@ -1,29 +1,61 @@
 package render
 
 import (
-	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/gobwas/glob"
+	yaml "gopkg.in/yaml.v2"
 )
 
 type VarsSource struct {
-	Key            string                `json:",omitempty"`
-	FromEnv        *VarsSourceEnv        `json:",omitempty"`
-	FromFile       *VarsSourceFile       `json:",omitempty"`
-	FromFileSlurp  *VarsSourceFileSlurp  `json:",omitempty"`
-	FromFilesSlurp *VarsSourceFilesSlurp `json:",omitempty"`
-	FromParameter  *VarsSourceParameter  `json:",omitempty"`
-	FromStdin      *VarsSourceStdin      `json:",omitempty"`
+	Key               string                   `json:",omitempty"`
+	Merge             string                   `json:",omitempty"`
+	MergeLists        string                   `json:",omitempty"`
+	MergeListKey      string                   `json:",omitempty"`
+	FromEnv           *VarsSourceEnv           `json:",omitempty"`
+	FromFile          *VarsSourceFile          `json:",omitempty"`
+	FromFileDocuments *VarsSourceFileDocuments `json:",omitempty"`
+	FromFileSlurp     *VarsSourceFileSlurp     `json:",omitempty"`
+	FromFilesSlurp    *VarsSourceFilesSlurp    `json:",omitempty"`
+	FromParameter     *VarsSourceParameter     `json:",omitempty"`
+	FromStdin         *VarsSourceStdin         `json:",omitempty"`
 }
 
-func (v *VarsSource) Load(vars Vars) error {
+// Load loads the source's variables and merges them into vars using the
+// source's merge strategy, falling back to the given defaults.
+func (v *VarsSource) Load(vars Vars, defaults mergeStrategy) error {
+	_, err := v.load(vars, defaults)
+	return err
+}
+
+// load merges the source's variables into vars and returns them as they
+// were read, nested under the source's key.
+func (v *VarsSource) load(vars Vars, defaults mergeStrategy) (Vars, error) {
+	merge := defaults.override(v.Merge, v.MergeLists, v.MergeListKey)
+	if err := merge.validate(); err != nil {
+		return nil, err
+	}
 	if v.FromFilesSlurp != nil {
 		files, err := v.FromFilesSlurp.Load()
-		vars[v.Key] = files
-		return err
+		loaded := Vars{v.Key: files}
+		vars.mergeWith(loaded, merge)
+		return loaded, err
+	}
+	if v.FromFileDocuments != nil {
+		if v.Key == "" {
+			return nil, fmt.Errorf("%s: a key is required to load documents as a list", v.FromFileDocuments.Path)
+		}
+		documents, err := v.FromFileDocuments.Load()
+		if err != nil {
+			return nil, err
+		}
+		loaded := Vars{v.Key: documents}
+		vars.mergeWith(loaded, merge)
+		return loaded, nil
 	}
 	if v.Key != "" {
 		if destination, ok := vars[v.Key].(map[string]interface{}); ok {
@@ -34,6 +66,30 @@ func (v *VarsSource) Load(vars Vars) error {
 			vars = Vars(destination)
 		}
 	}
+	loaded := Vars{}
+	if v.FromParameter != nil {
+		// Only the parameter's value is subject to the merge strategy; maps
+		// and lists along its path are kept.
+		path, value, err := v.FromParameter.parse()
+		if err != nil {
+			return nil, err
+		}
+		setPath(vars, path, value, merge)
+		setPath(loaded, path, value, merge)
+	} else {
+		err := v.read(loaded)
+		if err != nil {
+			return nil, err
+		}
+		vars.mergeWith(loaded, merge)
+	}
+	if v.Key != "" {
+		return Vars{v.Key: map[string]interface{}(loaded)}, nil
+	}
+	return loaded, nil
+}
+
+func (v *VarsSource) read(vars Vars) error {
 	if v.FromEnv != nil {
 		v.FromEnv.Load(vars)
 		return nil
@@ -45,8 +101,7 @@ func (v *VarsSource) Load(vars Vars) error {
 		return v.FromFileSlurp.Load(vars)
 	}
 	if v.FromParameter != nil {
-		v.FromParameter.Load(vars)
-		return nil
+		return v.FromParameter.Load(vars)
 	}
 	if v.FromStdin != nil {
 		return v.FromStdin.Load(vars)
@@ -54,13 +109,48 @@ func (v *VarsSource) Load(vars Vars) error {
 	return nil
 }
 
+// VarsSourceParameter sets the variable at the path Key (e.g. "db.hosts[0]")
+// to Value. If Format is "json" or "yaml", Value is parsed accordingly,
+// otherwise it is used as a string.
 type VarsSourceParameter struct {
-	Key   string
-	Value string
+	Key    string
+	Value  string
+	Format string `json:",omitempty"`
+}
+
+func (v *VarsSourceParameter) parse() ([]interface{}, interface{}, error) {
+	path, err := parsePath(v.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := path[0].(int); ok {
+		return nil, nil, fmt.Errorf("path %q: must start with a key", v.Key)
+	}
+	var value interface{}
+	switch v.Format {
+	case "":
+		value = v.Value
+	case "json":
+		err = json.Unmarshal([]byte(v.Value), &value)
+	case "yaml":
+		err = yaml.Unmarshal([]byte(v.Value), &value)
+		value = flatten(value)
+	default:
+		err = fmt.Errorf("unknown format %q", v.Format)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", v.Key, err)
+	}
+	return path, value, nil
 }
 
-func (v *VarsSourceParameter) Load(vars Vars) {
-	vars[v.Key] = v.Value
+func (v *VarsSourceParameter) Load(vars Vars) error {
+	path, value, err := v.parse()
+	if err != nil {
+		return err
+	}
+	setPath(vars, path, value, mergeStrategy{Maps: MergeOverwrite})
+	return nil
 }
 
 type VarsSourceFilesSlurp struct {
@@ -107,49 +197,43 @@ func (v VarsSourceFileSlurp) Load(vars Vars) error {
 	return nil
 }
 
-type VarsSourceStdin struct{}
+// VarsSourceStdin loads variables from stdin in the given Format. If no
+// Format is given, it is guessed from the input.
+type VarsSourceStdin struct {
+	Format string `json:",omitempty"`
+}
 
 func (v VarsSourceStdin) Load(vars Vars) error {
-	bs, err := ioutil.ReadAll(os.Stdin)
-	buf := bytes.NewBuffer(bs)
-	err = vars.fromJSON(buf)
-	if err != nil {
-		buf = bytes.NewBuffer(bs)
-		err = vars.fromYAML(buf)
-	}
-	if err != nil {
-		buf = bytes.NewBuffer(bs)
-		err = vars.fromTOML(buf)
-	}
+	bytes, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		return err
 	}
-	return nil
+	return vars.fromFormat("stdin", bytes, v.Format, false)
 }
 
+// VarsSourceFile loads variables from a file in the given Format (one of
+// Formats). If no Format is given, it is detected from the file's name, or
+// failing that guessed from its contents. If ExpandKeys is set, dotted keys
+// in .properties files are expanded into nested maps.
 type VarsSourceFile struct {
-	Path string
+	Path       string
+	Format     string `json:",omitempty"`
+	ExpandKeys bool   `json:",omitempty"`
 }
 
-func (v VarsSourceFile) Load(vars Vars) error {
-	f, err := os.Open(v.Path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	err = vars.fromJSON(f)
-	if err != nil {
-		f.Seek(0, os.SEEK_SET)
-		err = vars.fromYAML(f)
-	}
-	if err != nil {
-		f.Seek(0, os.SEEK_SET)
-		err = vars.fromTOML(f)
+func (v VarsSourceFile) format() string {
+	if v.Format != "" {
+		return v.Format
 	}
+	return formatOfPath(v.Path)
+}
+
+func (v VarsSourceFile) Load(vars Vars) error {
+	bytes, err := ioutil.ReadFile(v.Path)
 	if err != nil {
 		return err
 	}
-	return nil
+	return vars.fromFormat(v.Path, bytes, v.format(), v.ExpandKeys)
 }
 
 type VarsSourceEnv struct {
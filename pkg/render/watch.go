@@ -0,0 +1,170 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Paths returns the files the template source currently reads.
+func (ts *TemplateSource) Paths() ([]string, error) {
+	if ts.FromDir != nil {
+		paths := []string{}
+		err := ts.FromDir.walk(func(path, relPath string, info os.FileInfo) error {
+			paths = append(paths, path)
+			return nil
+		})
+		return paths, err
+	}
+	if ts.FromFile != nil {
+		return []string{ts.FromFile.Path}, nil
+	}
+	if ts.FromFileGlob != nil {
+		return filepath.Glob(ts.FromFileGlob.Glob)
+	}
+	return nil, nil
+}
+
+// Paths returns the files the variable source currently reads.
+func (v *VarsSource) Paths() ([]string, error) {
+	if v.FromFile != nil {
+		return []string{v.FromFile.Path}, nil
+	}
+	if v.FromFileDocuments != nil && v.FromFileDocuments.Path != "-" {
+		return []string{v.FromFileDocuments.Path}, nil
+	}
+	if v.FromFileSlurp != nil && v.FromFileSlurp.Path != "-" {
+		return []string{v.FromFileSlurp.Path}, nil
+	}
+	if v.FromFilesSlurp != nil {
+		return filepath.Glob(v.FromFilesSlurp.Glob)
+	}
+	return nil, nil
+}
+
+// Paths returns the files currently read by the config's template and variable
+// sources, its layout and its variable schema.
+func (c *Config) Paths() ([]string, error) {
+	seen := map[string]bool{}
+	paths := []string{}
+	add := func(sourcePaths []string, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, path := range sourcePaths {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	}
+	templateSources := append(append([]*TemplateSource{}, c.PartialSources...), c.TemplateSources...)
+	for _, templateSource := range templateSources {
+		err := add(templateSource.Paths())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.TemplateLayout != "" {
+		add([]string{c.TemplateLayout}, nil)
+	}
+	if c.VarsSchema != "" {
+		add([]string{c.VarsSchema}, nil)
+	}
+	for _, varsSource := range c.VarsSources {
+		err := add(varsSource.Paths())
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+type fileState struct {
+	ModTime time.Time
+	Size    int64
+	Mode    os.FileMode
+}
+
+// Watcher polls the files read by a config's sources for changes.
+type Watcher struct {
+	Config *Config
+	// Layouts are watched in addition to the config's files, since layouts
+	// chosen in front matter are only known once the templates are loaded
+	// (see Templates.LayoutPaths).
+	Layouts  []string
+	Interval time.Duration
+	Debounce time.Duration
+}
+
+func (w *Watcher) snapshot() map[string]fileState {
+	snapshot := map[string]fileState{}
+	paths, err := w.Config.Paths()
+	if err != nil {
+		// Directories vanishing mid-walk and the like; treat as a change
+		// and let the next poll settle it.
+		snapshot[""] = fileState{ModTime: time.Now()}
+		return snapshot
+	}
+	paths = append(paths, w.Layouts...)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			snapshot[path] = fileState{}
+			continue
+		}
+		snapshot[path] = fileState{
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+		}
+	}
+	return snapshot
+}
+
+func sameSnapshot(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		other, ok := b[path]
+		if !ok || !state.ModTime.Equal(other.ModTime) || state.Size != other.Size || state.Mode != other.Mode {
+			return false
+		}
+	}
+	return true
+}
+
+// Watch calls onChange whenever the set of files read by the config's
+// sources or their contents change. Bursts of changes are coalesced: onChange
+// is only called once no further change has been seen for the Debounce
+// period. Glob and directory sources are re-evaluated on every poll, so newly
+// matching files are picked up. Watch returns when stop is closed.
+func (w *Watcher) Watch(onChange func(), stop <-chan struct{}) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	current := w.snapshot()
+	var changedAt time.Time
+	pending := false
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			next := w.snapshot()
+			if !sameSnapshot(current, next) {
+				current = next
+				changedAt = now
+				pending = true
+				continue
+			}
+			if pending && now.Sub(changedAt) >= w.Debounce {
+				pending = false
+				onChange()
+			}
+		}
+	}
+}
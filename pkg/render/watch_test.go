@@ -0,0 +1,51 @@
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTemplateSourceDirPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, "a.tmpl", "b.txt", "sub/c.tmpl", "sub/d.tmpl")
+	source := &TemplateSource{FromDir: &TemplateSourceDir{Path: dir, Include: "**.tmpl", Exclude: "sub/d*"}}
+	paths, err := source.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(dir, "a.tmpl"), filepath.Join(dir, "sub", "c.tmpl")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %q, got %q", expected, paths)
+	}
+}
+
+func TestLayoutPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, "layout.html", "other.html")
+	page := filepath.Join(dir, "page.html")
+	err = ioutil.WriteFile(page, []byte("---\nlayout: other.html\n---\n{{ define \"body\" }}{{ end }}"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		TemplateLayout:      filepath.Join(dir, "layout.html"),
+		TemplateFrontMatter: true,
+		TemplateSources:     []*TemplateSource{{Name: "page.html", FromFile: &TemplateSourceFile{Path: page}}},
+	}
+	templates := parameterTemplates(t, config, "a", `{{ define "body" }}{{ end }}`)
+	expected := []string{filepath.Join(dir, "layout.html"), filepath.Join(dir, "other.html")}
+	if paths := templates.LayoutPaths(); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %q, got %q", expected, paths)
+	}
+}
@@ -0,0 +1,86 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeFileIfChanged writes data to filename unless the file already has
+// exactly that content. Existing files keep their permissions; new files are
+// created with perm (before umask). It reports whether the file was written.
+func writeFileIfChanged(filename string, data []byte, perm os.FileMode) (bool, error) {
+	keepMode := false
+	info, err := os.Stat(filename)
+	if err == nil && info.Mode().IsRegular() {
+		existing, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(existing, data) {
+			return false, nil
+		}
+		perm = info.Mode().Perm()
+		keepMode = true
+	}
+	return true, writeFileAtomic(filename, data, perm, keepMode)
+}
+
+// writeFileWithMode is like writeFileIfChanged, but sets the file's mode to
+// perm exactly, even if the file exists and its content does not change.
+func writeFileWithMode(filename string, data []byte, perm os.FileMode) (bool, error) {
+	info, err := os.Stat(filename)
+	if err == nil && info.Mode().IsRegular() {
+		existing, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(existing, data) {
+			if info.Mode().Perm() == perm {
+				return false, nil
+			}
+			return true, os.Chmod(filename, perm)
+		}
+	}
+	return true, writeFileAtomic(filename, data, perm, true)
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so that readers never observe a partially written
+// file. If exactMode is set, the file mode is set to perm regardless of umask.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode, exactMode bool) error {
+	dir, base := filepath.Split(filename)
+	var f *os.File
+	var tempPath string
+	var err error
+	for i := 0; i < 100; i++ {
+		tempPath = filepath.Join(dir, fmt.Sprintf(".%s.%d.render-tmp", base, time.Now().UnixNano()+int64(i)))
+		f, err = os.OpenFile(tempPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+		if !os.IsExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && exactMode {
+		err = os.Chmod(tempPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, filename)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}